@@ -227,6 +227,8 @@ mapType
     ;
 
 // Channel: *chan T (explicit pointer in Moxie)
+// Channel literals use the compat forms: &chan T{n}, &chan<- T{n} and
+// &<-chan T{n}; the AST builder turns them into ChanLit nodes.
 channelType
     : '*' 'chan' '<-'? elementType     # SendRecvChan
     | '*' '<-' 'chan' elementType      # RecvOnlyChan
@@ -605,7 +607,7 @@ VAR         : 'var';
 
 // Moxie-specific built-ins (not keywords, but recognized identifiers)
 // These are parsed as IDENTIFIER but semantically checked:
-// clone, copy, grow, clear, free, dlopen, dlsym, dlclose, dlerror, dlopen_mem
+// clone, copy, grow, clear, free, sort, assert, dlopen, dlsym, dlclose, dlerror, dlopen_mem
 // NOTE: append() is NOT a built-in - use | operator for concatenation
 
 // Types - Note: No 'int' or 'uint' in Moxie (explicit sizes required)
@@ -722,6 +724,8 @@ RAW_STRING_LIT
     : '`' ~'`'* '`'
     ;
 
+// Interpolations ("hello ${name}") are ordinary characters to the lexer;
+// the AST builder parses the embedded expressions.
 INTERPRETED_STRING_LIT
     : '"' ( ~["\\\r\n] | ESCAPE_SEQ | UNICODE_VALUE )* '"'
     ;
@@ -1,6 +1,8 @@
 package antlr
 
 import (
+	"fmt"
+
 	"github.com/antlr4-go/antlr/v4"
 	"github.com/mleku/moxie/pkg/ast"
 )
@@ -9,15 +11,18 @@ import (
 // It embeds BaseMoxieVisitor to implement the MoxieVisitor interface.
 type ASTBuilder struct {
 	BaseMoxieVisitor
-	filename string
-	errors   []error
+	filename  string
+	positions *PositionTable
+	comments  []antlr.Token
+	errors    []error
 }
 
 // NewASTBuilder creates a new AST builder for the given filename.
 func NewASTBuilder(filename string) *ASTBuilder {
 	return &ASTBuilder{
-		filename: filename,
-		errors:   []error{},
+		filename:  filename,
+		positions: NewPositionTable(filename),
+		errors:    []error{},
 	}
 }
 
@@ -33,19 +38,33 @@ func (b *ASTBuilder) addError(err error) {
 	}
 }
 
+// errorf adds a BuildError at pos to the error list.
+func (b *ASTBuilder) errorf(pos ast.Position, format string, args ...interface{}) {
+	b.addError(&BuildError{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
 // pos returns the starting position of a context.
 func (b *ASTBuilder) pos(ctx antlr.ParserRuleContext) ast.Position {
-	return ContextToPosition(ctx, b.filename)
+	if ctx == nil {
+		return ast.Position{}
+	}
+	return b.positions.Position(ctx.GetStart())
 }
 
-// endPos returns the ending position of a context.
+// endPos returns the position immediately after a context.
 func (b *ASTBuilder) endPos(ctx antlr.ParserRuleContext) ast.Position {
-	return ContextEndPosition(ctx, b.filename)
+	if ctx == nil {
+		return ast.Position{}
+	}
+	if ctx.GetStop() == nil {
+		return b.pos(ctx)
+	}
+	return b.positions.End(ctx.GetStop())
 }
 
 // tokenPos returns the position of a token.
 func (b *ASTBuilder) tokenPos(token antlr.Token) ast.Position {
-	return TokenToPosition(token, b.filename)
+	return b.positions.Position(token)
 }
 
 // ============================================================================
@@ -54,36 +73,37 @@ func (b *ASTBuilder) tokenPos(token antlr.Token) ast.Position {
 
 // VisitSourceFile transforms the top-level source file.
 func (b *ASTBuilder) VisitSourceFile(ctx *SourceFileContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
 	file := &ast.File{
 		StartPos: b.pos(ctx),
 		EndPos:   b.endPos(ctx),
 	}
 
 	// Package clause
-	if pkgCtx := ctx.PackageClause(); pkgCtx != nil {
-		if pkg, ok := pkgCtx.(*PackageClauseContext); ok {
-			file.Package = b.VisitPackageClause(pkg).(*ast.PackageClause)
-		}
+	if pkg, ok := b.visit(ctx.PackageClause()).(*ast.PackageClause); ok {
+		file.Package = pkg
 	}
 
 	// Imports
 	for _, importCtx := range ctx.AllImportDecl() {
-		if iCtx, ok := importCtx.(*ImportDeclContext); ok {
-			if decl := b.VisitImportDecl(iCtx); decl != nil {
-				file.Imports = append(file.Imports, decl.(*ast.ImportDecl))
-			}
+		if decl, ok := b.visit(importCtx).(*ast.ImportDecl); ok {
+			file.Imports = append(file.Imports, decl)
 		}
 	}
 
 	// Top-level declarations
 	for _, declCtx := range ctx.AllTopLevelDecl() {
-		if dCtx, ok := declCtx.(*TopLevelDeclContext); ok {
-			if decl := b.VisitTopLevelDecl(dCtx); decl != nil {
-				file.Decls = append(file.Decls, decl.(ast.Decl))
-			}
+		if decl, ok := b.visit(declCtx).(ast.Decl); ok {
+			file.Decls = append(file.Decls, decl)
 		}
 	}
 
+	// Comments
+	b.buildComments(file)
+
 	return file
 }
 
@@ -93,18 +113,10 @@ func (b *ASTBuilder) VisitPackageClause(ctx *PackageClauseContext) interface{} {
 		return nil
 	}
 
-	pkg := &ast.PackageClause{
-		Package: b.tokenPos(ctx.PACKAGE().GetSymbol()),
+	return &ast.PackageClause{
+		Package: b.terminalPos(ctx.PACKAGE()),
+		Name:    b.visitIdentifier(ctx.IDENTIFIER()),
 	}
-
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		pkg.Name = &ast.Ident{
-			NamePos: b.tokenPos(ident.GetSymbol()),
-			Name:    ident.GetText(),
-		}
-	}
-
-	return pkg
 }
 
 // VisitTopLevelDecl transforms a top-level declaration.
@@ -115,22 +127,16 @@ func (b *ASTBuilder) VisitTopLevelDecl(ctx *TopLevelDeclContext) interface{} {
 
 	// Function or method declaration
 	if funcCtx := ctx.FunctionDecl(); funcCtx != nil {
-		if fCtx, ok := funcCtx.(*FunctionDeclContext); ok {
-			return b.VisitFunctionDecl(fCtx)
-		}
+		return b.visit(funcCtx)
 	}
 
 	if methCtx := ctx.MethodDecl(); methCtx != nil {
-		if mCtx, ok := methCtx.(*MethodDeclContext); ok {
-			return b.VisitMethodDecl(mCtx)
-		}
+		return b.visit(methCtx)
 	}
 
 	// Other declarations
 	if declCtx := ctx.Declaration(); declCtx != nil {
-		if dCtx, ok := declCtx.(*DeclarationContext); ok {
-			return b.VisitDeclaration(dCtx)
-		}
+		return b.visit(declCtx)
 	}
 
 	return nil
@@ -143,21 +149,15 @@ func (b *ASTBuilder) VisitDeclaration(ctx *DeclarationContext) interface{} {
 	}
 
 	if constCtx := ctx.ConstDecl(); constCtx != nil {
-		if cCtx, ok := constCtx.(*ConstDeclContext); ok {
-			return b.VisitConstDecl(cCtx)
-		}
+		return b.visit(constCtx)
 	}
 
 	if typeCtx := ctx.TypeDecl(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*TypeDeclContext); ok {
-			return b.VisitTypeDecl(tCtx)
-		}
+		return b.visit(typeCtx)
 	}
 
 	if varCtx := ctx.VarDecl(); varCtx != nil {
-		if vCtx, ok := varCtx.(*VarDeclContext); ok {
-			return b.VisitVarDecl(vCtx)
-		}
+		return b.visit(varCtx)
 	}
 
 	return nil
@@ -174,15 +174,15 @@ func (b *ASTBuilder) VisitImportDecl(ctx *ImportDeclContext) interface{} {
 	}
 
 	decl := &ast.ImportDecl{
-		Import: b.tokenPos(ctx.IMPORT().GetSymbol()),
+		Import: b.terminalPos(ctx.IMPORT()),
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
 	}
 
 	// Get all import specs
 	for _, specCtx := range ctx.AllImportSpec() {
-		if sCtx, ok := specCtx.(*ImportSpecContext); ok {
-			if spec := b.VisitImportSpec(sCtx); spec != nil {
-				decl.Specs = append(decl.Specs, spec.(*ast.ImportSpec))
-			}
+		if spec, ok := b.visit(specCtx).(*ast.ImportSpec); ok {
+			decl.Specs = append(decl.Specs, spec)
 		}
 	}
 
@@ -199,19 +199,14 @@ func (b *ASTBuilder) VisitImportSpec(ctx *ImportSpecContext) interface{} {
 
 	// Import alias (., _, or identifier)
 	if ident := ctx.IDENTIFIER(); ident != nil {
-		spec.Name = &ast.Ident{
-			NamePos: b.tokenPos(ident.GetSymbol()),
-			Name:    ident.GetText(),
-		}
+		spec.Name = b.visitIdentifier(ident)
+	} else if dot := ctx.GetToken(tokenPeriod, 0); dot != nil {
+		spec.Name = b.visitIdentifier(dot)
 	}
 
 	// Import path (string literal)
-	if str := ctx.String_(); str != nil {
-		if sCtx, ok := str.(*String_Context); ok {
-			if lit := b.VisitString_(sCtx); lit != nil {
-				spec.Path = lit.(*ast.BasicLit)
-			}
-		}
+	if lit, ok := b.visit(ctx.String_()).(*ast.BasicLit); ok {
+		spec.Path = lit
 	}
 
 	return spec
@@ -221,6 +216,385 @@ func (b *ASTBuilder) VisitImportSpec(ctx *ImportSpecContext) interface{} {
 // Helper Methods
 // ============================================================================
 
+// visit dispatches a parse tree node to the matching Visit method. Rules
+// with labeled alternatives (type_, expression, statement, ...) resolve to
+// the method for the alternative that matched. The generated parser has
+// no Accept methods, so the dispatch is done here.
+func (b *ASTBuilder) visit(tree antlr.ParseTree) interface{} {
+	switch ctx := tree.(type) {
+	case *SourceFileContext:
+		return b.VisitSourceFile(ctx)
+	case *PackageClauseContext:
+		return b.VisitPackageClause(ctx)
+	case *TopLevelDeclContext:
+		return b.VisitTopLevelDecl(ctx)
+	case *DeclarationContext:
+		return b.VisitDeclaration(ctx)
+	case *ImportDeclContext:
+		return b.VisitImportDecl(ctx)
+	case *ImportSpecContext:
+		return b.VisitImportSpec(ctx)
+	case *ConstDeclContext:
+		return b.VisitConstDecl(ctx)
+	case *ConstSpecContext:
+		return b.VisitConstSpec(ctx)
+	case *VarDeclContext:
+		return b.VisitVarDecl(ctx)
+	case *VarSpecContext:
+		return b.VisitVarSpec(ctx)
+	case *TypeDeclContext:
+		return b.VisitTypeDecl(ctx)
+	case *TypeAliasContext:
+		return b.VisitTypeAlias(ctx)
+	case *TypeDefContext:
+		return b.VisitTypeDef(ctx)
+	case *TypeParametersContext:
+		return b.VisitTypeParameters(ctx)
+	case *TypeParameterDeclContext:
+		return b.VisitTypeParameterDecl(ctx)
+	case *TypeConstraintContext:
+		return b.VisitTypeConstraint(ctx)
+	case *FunctionDeclContext:
+		return b.VisitFunctionDecl(ctx)
+	case *MethodDeclContext:
+		return b.VisitMethodDecl(ctx)
+	case *ReceiverContext:
+		return b.VisitReceiver(ctx)
+	case *UnaryExpressionContext:
+		return b.VisitUnaryExpression(ctx)
+	case *MultiplicativeExprContext:
+		return b.VisitMultiplicativeExpr(ctx)
+	case *AdditiveExprContext:
+		return b.VisitAdditiveExpr(ctx)
+	case *ConcatenationExprContext:
+		return b.VisitConcatenationExpr(ctx)
+	case *RelationalExprContext:
+		return b.VisitRelationalExpr(ctx)
+	case *LogicalAndExprContext:
+		return b.VisitLogicalAndExpr(ctx)
+	case *LogicalOrExprContext:
+		return b.VisitLogicalOrExpr(ctx)
+	case *PrimaryOperandContext:
+		return b.VisitPrimaryOperand(ctx)
+	case *ConversionExprContext:
+		return b.VisitConversionExpr(ctx)
+	case *MethodExpressionContext:
+		return b.VisitMethodExpression(ctx)
+	case *MethodExprContext:
+		return b.VisitMethodExpr(ctx)
+	case *SelectorExprContext:
+		return b.VisitSelectorExpr(ctx)
+	case *IndexExprContext:
+		return b.VisitIndexExpr(ctx)
+	case *SliceExprContext:
+		return b.VisitSliceExpr(ctx)
+	case *TypeAssertionExprContext:
+		return b.VisitTypeAssertionExpr(ctx)
+	case *CallExprContext:
+		return b.VisitCallExpr(ctx)
+	case *UnaryExprContext:
+		return b.VisitUnaryExpr(ctx)
+	case *LiteralOperandContext:
+		return b.VisitLiteralOperand(ctx)
+	case *NameOperandContext:
+		return b.VisitNameOperand(ctx)
+	case *ParenOperandContext:
+		return b.VisitParenOperand(ctx)
+	case *OperandNameContext:
+		return b.VisitOperandName(ctx)
+	case *SelectorContext:
+		return b.VisitSelector(ctx)
+	case *IndexContext:
+		return b.VisitIndex(ctx)
+	case *Slice_Context:
+		return b.VisitSlice_(ctx)
+	case *TypeAssertionContext:
+		return b.VisitTypeAssertion(ctx)
+	case *ArgumentsContext:
+		return b.VisitArguments(ctx)
+	case *SimpleConversionContext:
+		return b.VisitSimpleConversion(ctx)
+	case *SliceCastExprContext:
+		return b.VisitSliceCastExpr(ctx)
+	case *SliceCastEndianExprContext:
+		return b.VisitSliceCastEndianExpr(ctx)
+	case *SliceCastCopyExprContext:
+		return b.VisitSliceCastCopyExpr(ctx)
+	case *SliceCastCopyEndianExprContext:
+		return b.VisitSliceCastCopyEndianExpr(ctx)
+	case *ExpressionListContext:
+		return b.VisitExpressionList(ctx)
+	case *Mul_opContext:
+		return b.VisitMul_op(ctx)
+	case *Add_opContext:
+		return b.VisitAdd_op(ctx)
+	case *Rel_opContext:
+		return b.VisitRel_op(ctx)
+	case *Unary_opContext:
+		return b.VisitUnary_op(ctx)
+	case *LiteralContext:
+		return b.VisitLiteral(ctx)
+	case *BasicLitContext:
+		return b.VisitBasicLit(ctx)
+	case *String_Context:
+		return b.VisitString_(ctx)
+	case *CompositeLitContext:
+		return b.VisitCompositeLit(ctx)
+	case *LiteralTypeContext:
+		return b.VisitLiteralType(ctx)
+	case *LiteralValueContext:
+		return b.VisitLiteralValue(ctx)
+	case *ElementListContext:
+		return b.VisitElementList(ctx)
+	case *KeyedElementContext:
+		return b.VisitKeyedElement(ctx)
+	case *KeyContext:
+		return b.VisitKey(ctx)
+	case *ElementContext:
+		return b.VisitElement(ctx)
+	case *FunctionLitContext:
+		return b.VisitFunctionLit(ctx)
+	case *BlockContext:
+		return b.VisitBlock(ctx)
+	case *StatementListContext:
+		return b.VisitStatementList(ctx)
+	case *DeclStmtContext:
+		return b.VisitDeclStmt(ctx)
+	case *SimpleStatementContext:
+		return b.VisitSimpleStatement(ctx)
+	case *LabeledStatementContext:
+		return b.VisitLabeledStatement(ctx)
+	case *GoStatementContext:
+		return b.VisitGoStatement(ctx)
+	case *ReturnStatementContext:
+		return b.VisitReturnStatement(ctx)
+	case *BreakStatementContext:
+		return b.VisitBreakStatement(ctx)
+	case *ContinueStatementContext:
+		return b.VisitContinueStatement(ctx)
+	case *GotoStatementContext:
+		return b.VisitGotoStatement(ctx)
+	case *FallthroughStatementContext:
+		return b.VisitFallthroughStatement(ctx)
+	case *BlockStatementContext:
+		return b.VisitBlockStatement(ctx)
+	case *IfStatementContext:
+		return b.VisitIfStatement(ctx)
+	case *SwitchStatementContext:
+		return b.VisitSwitchStatement(ctx)
+	case *SelectStatementContext:
+		return b.VisitSelectStatement(ctx)
+	case *ForStatementContext:
+		return b.VisitForStatement(ctx)
+	case *DeferStatementContext:
+		return b.VisitDeferStatement(ctx)
+	case *SimpleStmtContext:
+		return b.VisitSimpleStmt(ctx)
+	case *ExpressionStmtContext:
+		return b.VisitExpressionStmt(ctx)
+	case *SendStmtContext:
+		return b.VisitSendStmt(ctx)
+	case *IncDecStmtContext:
+		return b.VisitIncDecStmt(ctx)
+	case *AssignmentContext:
+		return b.VisitAssignment(ctx)
+	case *Assign_opContext:
+		return b.VisitAssign_op(ctx)
+	case *ShortVarDeclContext:
+		return b.VisitShortVarDecl(ctx)
+	case *ReturnStmtContext:
+		return b.VisitReturnStmt(ctx)
+	case *BreakStmtContext:
+		return b.VisitBreakStmt(ctx)
+	case *ContinueStmtContext:
+		return b.VisitContinueStmt(ctx)
+	case *GotoStmtContext:
+		return b.VisitGotoStmt(ctx)
+	case *FallthroughStmtContext:
+		return b.VisitFallthroughStmt(ctx)
+	case *DeferStmtContext:
+		return b.VisitDeferStmt(ctx)
+	case *GoStmtContext:
+		return b.VisitGoStmt(ctx)
+	case *LabeledStmtContext:
+		return b.VisitLabeledStmt(ctx)
+	case *IfStmtContext:
+		return b.VisitIfStmt(ctx)
+	case *SwitchStmtContext:
+		return b.VisitSwitchStmt(ctx)
+	case *ExprSwitchStmtContext:
+		return b.VisitExprSwitchStmt(ctx)
+	case *ExprCaseClauseContext:
+		return b.VisitExprCaseClause(ctx)
+	case *ExprSwitchCaseContext:
+		return b.VisitExprSwitchCase(ctx)
+	case *TypeSwitchStmtContext:
+		return b.VisitTypeSwitchStmt(ctx)
+	case *TypeSwitchGuardContext:
+		return b.VisitTypeSwitchGuard(ctx)
+	case *TypeCaseClauseContext:
+		return b.VisitTypeCaseClause(ctx)
+	case *TypeSwitchCaseContext:
+		return b.VisitTypeSwitchCase(ctx)
+	case *SelectStmtContext:
+		return b.VisitSelectStmt(ctx)
+	case *CommClauseContext:
+		return b.VisitCommClause(ctx)
+	case *CommCaseContext:
+		return b.VisitCommCase(ctx)
+	case *RecvStmtContext:
+		return b.VisitRecvStmt(ctx)
+	case *ForStmtContext:
+		return b.VisitForStmt(ctx)
+	case *ForClauseContext:
+		return b.VisitForClause(ctx)
+	case *RangeClauseContext:
+		return b.VisitRangeClause(ctx)
+	case *NamedTypeContext:
+		return b.VisitNamedType(ctx)
+	case *TypeArgsContext:
+		return b.VisitTypeArgs(ctx)
+	case *TypeListContext:
+		return b.VisitTypeList(ctx)
+	case *TypeNameContext:
+		return b.VisitTypeName(ctx)
+	case *TypeLiteralContext:
+		return b.VisitTypeLiteral(ctx)
+	case *TypeLitContext:
+		return b.VisitTypeLit(ctx)
+	case *ParenTypeContext:
+		return b.VisitParenType(ctx)
+	case *PointerTypeContext:
+		return b.VisitPointerType(ctx)
+	case *SliceTypeContext:
+		return b.VisitSliceType(ctx)
+	case *ElementTypeContext:
+		return b.VisitElementType(ctx)
+	case *ArrayTypeContext:
+		return b.VisitArrayType(ctx)
+	case *ArrayLengthContext:
+		return b.VisitArrayLength(ctx)
+	case *StructTypeContext:
+		return b.VisitStructType(ctx)
+	case *FieldDeclContext:
+		return b.VisitFieldDecl(ctx)
+	case *EmbeddedFieldContext:
+		return b.VisitEmbeddedField(ctx)
+	case *Tag_Context:
+		return b.VisitTag_(ctx)
+	case *InterfaceTypeContext:
+		return b.VisitInterfaceType(ctx)
+	case *InterfaceElemContext:
+		return b.VisitInterfaceElem(ctx)
+	case *MethodElemContext:
+		return b.VisitMethodElem(ctx)
+	case *TypeElemContext:
+		return b.VisitTypeElem(ctx)
+	case *TypeTermContext:
+		return b.VisitTypeTerm(ctx)
+	case *MapTypeContext:
+		return b.VisitMapType(ctx)
+	case *SendRecvChanContext:
+		return b.VisitSendRecvChan(ctx)
+	case *RecvOnlyChanContext:
+		return b.VisitRecvOnlyChan(ctx)
+	case *SendRecvChanCompatContext:
+		return b.VisitSendRecvChanCompat(ctx)
+	case *RecvOnlyChanCompatContext:
+		return b.VisitRecvOnlyChanCompat(ctx)
+	case *FunctionTypeContext:
+		return b.VisitFunctionType(ctx)
+	case *SignatureContext:
+		return b.VisitSignature(ctx)
+	case *ParametersContext:
+		return b.VisitParameters(ctx)
+	case *ParameterDeclContext:
+		return b.VisitParameterDecl(ctx)
+	case *ResultContext:
+		return b.VisitResult(ctx)
+	case *ConstTypeContext:
+		return b.VisitConstType(ctx)
+	case *QualifiedIdentContext:
+		return b.VisitQualifiedIdent(ctx)
+	}
+	return nil
+}
+
+// visitExpr visits tree and returns the resulting expression, or nil.
+func (b *ASTBuilder) visitExpr(tree antlr.ParseTree) ast.Expr {
+	if expr, ok := b.visit(tree).(ast.Expr); ok {
+		return expr
+	}
+	return nil
+}
+
+// visitOperand visits tree, an operand the grammar requires, and returns
+// the resulting expression, or a bad expression at pos if the parser
+// recovered from a syntax error without it.
+func (b *ASTBuilder) visitOperand(tree antlr.ParseTree, pos ast.Position) ast.Expr {
+	if expr := b.visitExpr(tree); expr != nil {
+		return expr
+	}
+	return &ast.BadExpr{From: pos, To: pos}
+}
+
+// visitType visits tree and returns the resulting type, or nil.
+func (b *ASTBuilder) visitType(tree antlr.ParseTree) ast.Type {
+	if typ, ok := b.visit(tree).(ast.Type); ok {
+		return typ
+	}
+	return nil
+}
+
+// visitStmt visits tree and returns the resulting statement, or nil.
+func (b *ASTBuilder) visitStmt(tree antlr.ParseTree) ast.Stmt {
+	if stmt, ok := b.visit(tree).(ast.Stmt); ok {
+		return stmt
+	}
+	return nil
+}
+
+// visitExprList visits an expression list.
+func (b *ASTBuilder) visitExprList(ctx IExpressionListContext) []ast.Expr {
+	if exprs, ok := b.visit(ctx).([]ast.Expr); ok {
+		return exprs
+	}
+	return nil
+}
+
+// visitStmtList visits a statement list.
+func (b *ASTBuilder) visitStmtList(ctx IStatementListContext) []ast.Stmt {
+	if stmts, ok := b.visit(ctx).([]ast.Stmt); ok {
+		return stmts
+	}
+	return nil
+}
+
+// visitBlock visits a block.
+func (b *ASTBuilder) visitBlock(ctx IBlockContext) *ast.BlockStmt {
+	if block, ok := b.visit(ctx).(*ast.BlockStmt); ok {
+		return block
+	}
+	return nil
+}
+
+// visitFieldList visits parameters or a receiver.
+func (b *ASTBuilder) visitFieldList(tree antlr.ParseTree) *ast.FieldList {
+	if list, ok := b.visit(tree).(*ast.FieldList); ok {
+		return list
+	}
+	return nil
+}
+
+// terminalPos returns the position of a terminal node, or an invalid
+// position if the node is absent.
+func (b *ASTBuilder) terminalPos(node antlr.TerminalNode) ast.Position {
+	if node == nil {
+		return ast.Position{}
+	}
+	return b.tokenPos(node.GetSymbol())
+}
+
 // visitIdentifier creates an identifier from a token.
 func (b *ASTBuilder) visitIdentifier(token antlr.TerminalNode) *ast.Ident {
 	if token == nil {
@@ -246,8 +620,10 @@ func (b *ASTBuilder) visitIdentifierList(ctx IIdentifierListContext) []*ast.Iden
 }
 
 // BuildAST is the main entry point for building an AST from a parse tree.
-func BuildAST(tree *SourceFileContext, filename string) (*ast.File, []error) {
+// The parse tree holds no comments, so the file has none; use ParseFile,
+// or an ASTBuilder with SetComments, to include them.
+func BuildAST(tree ISourceFileContext, filename string) (*ast.File, []error) {
 	builder := NewASTBuilder(filename)
-	file := builder.VisitSourceFile(tree).(*ast.File)
+	file, _ := builder.visit(tree).(*ast.File)
 	return file, builder.Errors()
 }
@@ -0,0 +1,122 @@
+package antlr
+
+import (
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// SetComments sets the comment tokens of the source, as returned by
+// Scanner.Comments, so the built file gets its comments and doc comments.
+func (b *ASTBuilder) SetComments(comments []antlr.Token) {
+	b.comments = comments
+}
+
+// commentGroup is a comment group with the placement information needed to
+// attach it to a node.
+type commentGroup struct {
+	group    *ast.CommentGroup
+	trailing bool // Starts on a line after other source text
+	used     bool // Attached to a node
+}
+
+// buildComments groups the comment tokens and attaches doc and line
+// comments to the declarations, specs and fields of file.
+func (b *ASTBuilder) buildComments(file *ast.File) {
+	groups := b.commentGroups()
+	if len(groups) == 0 {
+		return
+	}
+	for _, g := range groups {
+		file.Comments = append(file.Comments, g.group)
+	}
+
+	// Doc comments end on the line before the node; line comments start on
+	// the line the node ends on. Declarations come before their specs, so
+	// an ungrouped declaration takes the doc comment rather than its spec.
+	doc := func(n ast.Node) *ast.CommentGroup {
+		pos := n.Pos()
+		for _, g := range groups {
+			if !g.used && !g.trailing && g.group.End().Line == pos.Line-1 {
+				g.used = true
+				return g.group
+			}
+		}
+		return nil
+	}
+	line := func(n ast.Node) *ast.CommentGroup {
+		end := n.End()
+		for _, g := range groups {
+			if pos := g.group.Pos(); g.trailing && pos.Line == end.Line && pos.Offset >= end.Offset {
+				g.used = true
+				return g.group
+			}
+		}
+		return nil
+	}
+
+	if file.Package != nil {
+		file.Doc = doc(file.Package)
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportDecl:
+			n.Doc = doc(n)
+		case *ast.ConstDecl:
+			n.Doc = doc(n)
+		case *ast.VarDecl:
+			n.Doc = doc(n)
+		case *ast.TypeDecl:
+			n.Doc = doc(n)
+		case *ast.FuncDecl:
+			n.Doc = doc(n)
+		case *ast.ImportSpec:
+			n.Doc, n.Comment = doc(n), line(n)
+		case *ast.ConstSpec:
+			n.Doc, n.Comment = doc(n), line(n)
+		case *ast.VarSpec:
+			n.Doc, n.Comment = doc(n), line(n)
+		case *ast.TypeSpec:
+			n.Doc, n.Comment = doc(n), line(n)
+		case *ast.Field:
+			n.Doc, n.Comment = doc(n), line(n)
+		}
+		return true
+	})
+}
+
+// commentGroups converts the comment tokens to comments and groups
+// adjacent ones. A comment on the line where the previous one ends joins
+// its group, as does one on the next line that is alone on its line,
+// unless the group is a line comment after source text.
+func (b *ASTBuilder) commentGroups() []*commentGroup {
+	var groups []*commentGroup
+	var last *ast.Comment
+	for _, tok := range b.comments {
+		c := &ast.Comment{Slash: b.tokenPos(tok), Text: tok.GetText()}
+		trailing := followsCode(tok)
+		join := last != nil && (c.Slash.Line == last.End().Line ||
+			c.Slash.Line == last.End().Line+1 && !trailing && !groups[len(groups)-1].trailing)
+		if !join {
+			groups = append(groups, &commentGroup{
+				group:    &ast.CommentGroup{},
+				trailing: trailing,
+			})
+		}
+		g := groups[len(groups)-1].group
+		g.List = append(g.List, c)
+		last = c
+	}
+	return groups
+}
+
+// followsCode reports whether other source text precedes tok on its line.
+func followsCode(tok antlr.Token) bool {
+	start := tok.GetStart()
+	lineStart := start - tok.GetColumn()
+	if start <= lineStart || tok.GetInputStream() == nil {
+		return false
+	}
+	return strings.TrimSpace(tok.GetInputStream().GetText(lineStart, start-1)) != ""
+}
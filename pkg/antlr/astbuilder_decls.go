@@ -15,15 +15,15 @@ func (b *ASTBuilder) VisitConstDecl(ctx *ConstDeclContext) interface{} {
 	}
 
 	decl := &ast.ConstDecl{
-		Const: b.tokenPos(ctx.CONST().GetSymbol()),
+		Const:  b.terminalPos(ctx.CONST()),
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
 	}
 
 	// Get all const specs
 	for _, specCtx := range ctx.AllConstSpec() {
-		if sCtx, ok := specCtx.(*ConstSpecContext); ok {
-			if spec := b.VisitConstSpec(sCtx); spec != nil {
-				decl.Specs = append(decl.Specs, spec.(*ast.ConstSpec))
-			}
+		if spec, ok := b.visit(specCtx).(*ast.ConstSpec); ok {
+			decl.Specs = append(decl.Specs, spec)
 		}
 	}
 
@@ -36,34 +36,11 @@ func (b *ASTBuilder) VisitConstSpec(ctx *ConstSpecContext) interface{} {
 		return nil
 	}
 
-	spec := &ast.ConstSpec{}
-
-	// Constant names
-	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
-		if idList, ok := idListCtx.(*IdentifierListContext); ok {
-			spec.Names = b.visitIdentifierList(idList)
-		}
-	}
-
-	// Type (optional)
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			if typ := b.VisitType_(tCtx); typ != nil {
-				spec.Type = typ.(ast.Type)
-			}
-		}
+	return &ast.ConstSpec{
+		Names:  b.visitIdentifierList(ctx.IdentifierList()),
+		Type:   b.visitType(ctx.Type_()),
+		Values: b.visitExprList(ctx.ExpressionList()),
 	}
-
-	// Values
-	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		if eCtx, ok := exprListCtx.(*ExpressionListContext); ok {
-			if exprs := b.VisitExpressionList(eCtx); exprs != nil {
-				spec.Values = exprs.([]ast.Expr)
-			}
-		}
-	}
-
-	return spec
 }
 
 // ============================================================================
@@ -77,15 +54,15 @@ func (b *ASTBuilder) VisitVarDecl(ctx *VarDeclContext) interface{} {
 	}
 
 	decl := &ast.VarDecl{
-		Var: b.tokenPos(ctx.VAR().GetSymbol()),
+		Var:    b.terminalPos(ctx.VAR()),
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
 	}
 
 	// Get all var specs
 	for _, specCtx := range ctx.AllVarSpec() {
-		if sCtx, ok := specCtx.(*VarSpecContext); ok {
-			if spec := b.VisitVarSpec(sCtx); spec != nil {
-				decl.Specs = append(decl.Specs, spec.(*ast.VarSpec))
-			}
+		if spec, ok := b.visit(specCtx).(*ast.VarSpec); ok {
+			decl.Specs = append(decl.Specs, spec)
 		}
 	}
 
@@ -98,34 +75,11 @@ func (b *ASTBuilder) VisitVarSpec(ctx *VarSpecContext) interface{} {
 		return nil
 	}
 
-	spec := &ast.VarSpec{}
-
-	// Variable names
-	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
-		if idList, ok := idListCtx.(*IdentifierListContext); ok {
-			spec.Names = b.visitIdentifierList(idList)
-		}
+	return &ast.VarSpec{
+		Names:  b.visitIdentifierList(ctx.IdentifierList()),
+		Type:   b.visitType(ctx.Type_()),
+		Values: b.visitExprList(ctx.ExpressionList()),
 	}
-
-	// Type (optional if values are present)
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			if typ := b.VisitType_(tCtx); typ != nil {
-				spec.Type = typ.(ast.Type)
-			}
-		}
-	}
-
-	// Values (optional)
-	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		if eCtx, ok := exprListCtx.(*ExpressionListContext); ok {
-			if exprs := b.VisitExpressionList(eCtx); exprs != nil {
-				spec.Values = exprs.([]ast.Expr)
-			}
-		}
-	}
-
-	return spec
 }
 
 // ============================================================================
@@ -139,20 +93,15 @@ func (b *ASTBuilder) VisitTypeDecl(ctx *TypeDeclContext) interface{} {
 	}
 
 	decl := &ast.TypeDecl{
-		Type: b.tokenPos(ctx.TYPE().GetSymbol()),
+		Type:   b.terminalPos(ctx.TYPE()),
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
 	}
 
-	// Get all type specs
+	// Get all type specs (TypeAlias or TypeDef)
 	for _, specCtx := range ctx.AllTypeSpec() {
-		// TypeSpec is an interface - check for concrete types
-		if aliasCtx, ok := specCtx.(*TypeAliasContext); ok {
-			if spec := b.VisitTypeAlias(aliasCtx); spec != nil {
-				decl.Specs = append(decl.Specs, spec.(*ast.TypeSpec))
-			}
-		} else if defCtx, ok := specCtx.(*TypeDefContext); ok {
-			if spec := b.VisitTypeDef(defCtx); spec != nil {
-				decl.Specs = append(decl.Specs, spec.(*ast.TypeSpec))
-			}
+		if spec, ok := b.visit(specCtx).(*ast.TypeSpec); ok {
+			decl.Specs = append(decl.Specs, spec)
 		}
 	}
 
@@ -165,27 +114,12 @@ func (b *ASTBuilder) VisitTypeAlias(ctx *TypeAliasContext) interface{} {
 		return nil
 	}
 
-	spec := &ast.TypeSpec{}
-
-	// Type name
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		spec.Name = b.visitIdentifier(ident)
-	}
-
-	// Mark as alias - type aliases have "=" in the grammar
-	// We'll use a position to mark it (the actual "=" token position would need grammar analysis)
-	spec.Assign = b.pos(ctx)
-
-	// Underlying type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			if typ := b.VisitType_(tCtx); typ != nil {
-				spec.Type = typ.(ast.Type)
-			}
-		}
+	return &ast.TypeSpec{
+		Name:       b.visitIdentifier(ctx.IDENTIFIER()),
+		TypeParams: b.visitFieldList(ctx.TypeParameters()),
+		Assign:     b.terminalPos(ctx.GetToken(tokenAssign, 0)),
+		Type:       b.visitType(ctx.Type_()),
 	}
-
-	return spec
 }
 
 // VisitTypeDef transforms a type definition (type A B).
@@ -194,32 +128,11 @@ func (b *ASTBuilder) VisitTypeDef(ctx *TypeDefContext) interface{} {
 		return nil
 	}
 
-	spec := &ast.TypeSpec{}
-
-	// Type name
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		spec.Name = b.visitIdentifier(ident)
-	}
-
-	// Type parameters (generics)
-	if typeParamsCtx := ctx.TypeParameters(); typeParamsCtx != nil {
-		if tpCtx, ok := typeParamsCtx.(*TypeParametersContext); ok {
-			if typeParams := b.VisitTypeParameters(tpCtx); typeParams != nil {
-				spec.TypeParams = typeParams.(*ast.FieldList)
-			}
-		}
-	}
-
-	// Underlying type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			if typ := b.VisitType_(tCtx); typ != nil {
-				spec.Type = typ.(ast.Type)
-			}
-		}
+	return &ast.TypeSpec{
+		Name:       b.visitIdentifier(ctx.IDENTIFIER()),
+		TypeParams: b.visitFieldList(ctx.TypeParameters()),
+		Type:       b.visitType(ctx.Type_()),
 	}
-
-	return spec
 }
 
 // VisitTypeParameters transforms type parameters (generics).
@@ -230,15 +143,13 @@ func (b *ASTBuilder) VisitTypeParameters(ctx *TypeParametersContext) interface{}
 
 	fieldList := &ast.FieldList{
 		Opening: b.pos(ctx),
-		Closing: b.endPos(ctx),
+		Closing: b.tokenPos(ctx.GetStop()),
 	}
 
 	// Add type parameter declarations
 	for _, paramCtx := range ctx.AllTypeParameterDecl() {
-		if pCtx, ok := paramCtx.(*TypeParameterDeclContext); ok {
-			if param := b.VisitTypeParameterDecl(pCtx); param != nil {
-				fieldList.List = append(fieldList.List, param.(*ast.Field))
-			}
+		if param, ok := b.visit(paramCtx).(*ast.Field); ok {
+			fieldList.List = append(fieldList.List, param)
 		}
 	}
 
@@ -251,25 +162,10 @@ func (b *ASTBuilder) VisitTypeParameterDecl(ctx *TypeParameterDeclContext) inter
 		return nil
 	}
 
-	field := &ast.Field{}
-
-	// Type parameter names
-	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
-		if idList, ok := idListCtx.(*IdentifierListContext); ok {
-			field.Names = b.visitIdentifierList(idList)
-		}
+	return &ast.Field{
+		Names: b.visitIdentifierList(ctx.IdentifierList()),
+		Type:  b.visitType(ctx.TypeConstraint()),
 	}
-
-	// Type constraint
-	if constraintCtx := ctx.TypeConstraint(); constraintCtx != nil {
-		if cCtx, ok := constraintCtx.(*TypeConstraintContext); ok {
-			if constraint := b.VisitTypeConstraint(cCtx); constraint != nil {
-				field.Type = constraint.(ast.Type)
-			}
-		}
-	}
-
-	return field
 }
 
 // VisitTypeConstraint transforms a type constraint.
@@ -277,14 +173,7 @@ func (b *ASTBuilder) VisitTypeConstraint(ctx *TypeConstraintContext) interface{}
 	if ctx == nil {
 		return nil
 	}
-
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			return b.VisitType_(tCtx)
-		}
-	}
-
-	return nil
+	return b.visit(ctx.Type_())
 }
 
 // ============================================================================
@@ -297,30 +186,14 @@ func (b *ASTBuilder) VisitFunctionDecl(ctx *FunctionDeclContext) interface{} {
 		return nil
 	}
 
-	decl := &ast.FuncDecl{}
-
-	// Function name
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		decl.Name = b.visitIdentifier(ident)
-	}
-
-	// Function signature
-	if sigCtx := ctx.Signature(); sigCtx != nil {
-		if sCtx, ok := sigCtx.(*SignatureContext); ok {
-			if sig := b.VisitSignature(sCtx); sig != nil {
-				decl.Type = sig.(*ast.FuncType)
-			}
-		}
-	}
-
-	// Function body (may be nil for external/FFI functions)
-	if blockCtx := ctx.Block(); blockCtx != nil {
-		if bCtx, ok := blockCtx.(*BlockContext); ok {
-			if block := b.VisitBlock(bCtx); block != nil {
-				decl.Body = block.(*ast.BlockStmt)
-			}
-		}
+	decl := &ast.FuncDecl{
+		Name: b.visitIdentifier(ctx.IDENTIFIER()),
+		Type: b.funcType(ctx.Signature()),
+		// Body may be nil for external/FFI functions
+		Body: b.visitBlock(ctx.Block()),
 	}
+	decl.Type.Func = b.terminalPos(ctx.FUNC())
+	decl.Type.TypeParams = b.visitFieldList(ctx.TypeParameters())
 
 	return decl
 }
@@ -331,41 +204,24 @@ func (b *ASTBuilder) VisitMethodDecl(ctx *MethodDeclContext) interface{} {
 		return nil
 	}
 
-	decl := &ast.FuncDecl{}
-
-	// Receiver
-	if recvCtx := ctx.Receiver(); recvCtx != nil {
-		if rCtx, ok := recvCtx.(*ReceiverContext); ok {
-			if recv := b.VisitReceiver(rCtx); recv != nil {
-				decl.Recv = recv.(*ast.FieldList)
-			}
-		}
-	}
-
-	// Method name
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		decl.Name = b.visitIdentifier(ident)
+	decl := &ast.FuncDecl{
+		Recv: b.visitFieldList(ctx.Receiver()),
+		Name: b.visitIdentifier(ctx.IDENTIFIER()),
+		Type: b.funcType(ctx.Signature()),
+		Body: b.visitBlock(ctx.Block()),
 	}
+	decl.Type.Func = b.terminalPos(ctx.FUNC())
 
-	// Method signature
-	if sigCtx := ctx.Signature(); sigCtx != nil {
-		if sCtx, ok := sigCtx.(*SignatureContext); ok {
-			if sig := b.VisitSignature(sCtx); sig != nil {
-				decl.Type = sig.(*ast.FuncType)
-			}
-		}
-	}
+	return decl
+}
 
-	// Method body
-	if blockCtx := ctx.Block(); blockCtx != nil {
-		if bCtx, ok := blockCtx.(*BlockContext); ok {
-			if block := b.VisitBlock(bCtx); block != nil {
-				decl.Body = block.(*ast.BlockStmt)
-			}
-		}
+// funcType visits a signature. It never returns nil, so that the FuncDecl
+// always has a Type.
+func (b *ASTBuilder) funcType(ctx ISignatureContext) *ast.FuncType {
+	if funcType, ok := b.visit(ctx).(*ast.FuncType); ok {
+		return funcType
 	}
-
-	return decl
+	return &ast.FuncType{}
 }
 
 // VisitReceiver transforms a method receiver.
@@ -374,29 +230,17 @@ func (b *ASTBuilder) VisitReceiver(ctx *ReceiverContext) interface{} {
 		return nil
 	}
 
-	fieldList := &ast.FieldList{
-		Opening: b.pos(ctx),
-		Closing: b.endPos(ctx),
+	// Receiver is a single parameter, the name is optional
+	field := &ast.Field{
+		Type: b.visitType(ctx.Type_()),
 	}
-
-	// Receiver is a single parameter
-	field := &ast.Field{}
-
-	// Receiver name (optional)
 	if ident := ctx.IDENTIFIER(); ident != nil {
 		field.Names = []*ast.Ident{b.visitIdentifier(ident)}
 	}
 
-	// Receiver type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if tCtx, ok := typeCtx.(*Type_Context); ok {
-			if typ := b.VisitType_(tCtx); typ != nil {
-				field.Type = typ.(ast.Type)
-			}
-		}
+	return &ast.FieldList{
+		Opening: b.pos(ctx),
+		List:    []*ast.Field{field},
+		Closing: b.tokenPos(ctx.GetStop()),
 	}
-
-	fieldList.List = []*ast.Field{field}
-
-	return fieldList
 }
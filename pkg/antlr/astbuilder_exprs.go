@@ -1,6 +1,9 @@
 package antlr
 
 import (
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
 	"github.com/mleku/moxie/pkg/ast"
 )
 
@@ -8,140 +11,200 @@ import (
 // Expressions
 // ============================================================================
 
-// VisitExpression transforms an expression (handles precedence).
-func (b *ASTBuilder) VisitExpression(ctx *ExpressionContext) interface{} {
+// VisitUnaryExpression transforms the unary alternative of an expression.
+func (b *ASTBuilder) VisitUnaryExpression(ctx *UnaryExpressionContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
+	return b.visit(ctx.UnaryExpr())
+}
 
-	// Check for unary expression first
-	if unaryCtx := ctx.UnaryExpr(); unaryCtx != nil {
-		return b.VisitUnaryExpr(unaryCtx)
+// VisitMultiplicativeExpr transforms x * y, x / y, x % y, x << y, ...
+func (b *ASTBuilder) VisitMultiplicativeExpr(ctx *MultiplicativeExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	op, _ := b.visit(ctx.Mul_op()).(ast.Token)
+	return b.binaryExpr(ctx.AllExpression(), ctx.Mul_op(), op)
+}
 
-	// Check for primary expression
-	if primaryCtx := ctx.PrimaryExpr(); primaryCtx != nil {
-		return b.VisitPrimaryExpr(primaryCtx)
+// VisitAdditiveExpr transforms x + y, x - y and x ^ y.
+func (b *ASTBuilder) VisitAdditiveExpr(ctx *AdditiveExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	op, _ := b.visit(ctx.Add_op()).(ast.Token)
+	return b.binaryExpr(ctx.AllExpression(), ctx.Add_op(), op)
+}
 
-	// Binary expression (left op right)
-	exprs := ctx.AllExpression()
-	if len(exprs) >= 2 {
-		left := b.VisitExpression(exprs[0])
-		right := b.VisitExpression(exprs[1])
+// VisitConcatenationExpr transforms x | y (Moxie concatenation).
+func (b *ASTBuilder) VisitConcatenationExpr(ctx *ConcatenationExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.binaryExpr(ctx.AllExpression(), ctx.GetToken(tokenOr, 0), ast.OR)
+}
 
-		if left != nil && right != nil {
-			binary := &ast.BinaryExpr{
-				X:     left.(ast.Expr),
-				OpPos: b.pos(ctx),
-				Y:     right.(ast.Expr),
-			}
+// VisitRelationalExpr transforms comparisons.
+func (b *ASTBuilder) VisitRelationalExpr(ctx *RelationalExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	op, _ := b.visit(ctx.Rel_op()).(ast.Token)
+	return b.binaryExpr(ctx.AllExpression(), ctx.Rel_op(), op)
+}
 
-			// Determine operator from context
-			if mulOp := ctx.Mul_op(); mulOp != nil {
-				binary.Op = b.VisitMul_op(mulOp).(ast.Token)
-			} else if addOp := ctx.Add_op(); addOp != nil {
-				binary.Op = b.VisitAdd_op(addOp).(ast.Token)
-			} else if relOp := ctx.Rel_op(); relOp != nil {
-				binary.Op = b.VisitRel_op(relOp).(ast.Token)
-			}
+// VisitLogicalAndExpr transforms x && y.
+func (b *ASTBuilder) VisitLogicalAndExpr(ctx *LogicalAndExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.binaryExpr(ctx.AllExpression(), ctx.GetToken(tokenLAnd, 0), ast.LAND)
+}
 
-			return binary
-		}
+// VisitLogicalOrExpr transforms x || y.
+func (b *ASTBuilder) VisitLogicalOrExpr(ctx *LogicalOrExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.binaryExpr(ctx.AllExpression(), ctx.GetToken(tokenLOr, 0), ast.LOR)
+}
 
-	// Fallback to first expression
-	if len(exprs) > 0 {
-		return b.VisitExpression(exprs[0])
+// binaryExpr builds a binary expression from its two operands and the
+// operator node (a rule context or a terminal).
+func (b *ASTBuilder) binaryExpr(exprs []IExpressionContext, opNode antlr.ParseTree, op ast.Token) interface{} {
+	if len(exprs) < 2 {
+		return nil
 	}
 
-	return nil
+	binary := &ast.BinaryExpr{Op: op}
+	switch n := opNode.(type) {
+	case antlr.TerminalNode:
+		binary.OpPos = b.terminalPos(n)
+	case antlr.ParserRuleContext:
+		binary.OpPos = b.pos(n)
+	}
+	binary.X = b.visitOperand(exprs[0], binary.OpPos)
+	binary.Y = b.visitOperand(exprs[1], binary.OpPos)
+
+	return binary
 }
 
-// VisitPrimaryExpr transforms a primary expression.
-func (b *ASTBuilder) VisitPrimaryExpr(ctx *PrimaryExprContext) interface{} {
+// VisitPrimaryOperand transforms an operand.
+func (b *ASTBuilder) VisitPrimaryOperand(ctx *PrimaryOperandContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
+	return b.visit(ctx.Operand())
+}
 
-	// Operand (literal, identifier, etc.)
-	if operandCtx := ctx.Operand(); operandCtx != nil {
-		return b.VisitOperand(operandCtx)
+// VisitConversionExpr transforms a conversion.
+func (b *ASTBuilder) VisitConversionExpr(ctx *ConversionExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.Conversion())
+}
 
-	// Conversion
-	if convCtx := ctx.Conversion(); convCtx != nil {
-		return b.VisitConversion(convCtx)
+// VisitMethodExpression transforms a method expression.
+func (b *ASTBuilder) VisitMethodExpression(ctx *MethodExpressionContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.MethodExpr())
+}
 
-	// Selector (x.y)
-	if selCtx := ctx.Selector(); selCtx != nil {
-		base := b.VisitPrimaryExpr(ctx.PrimaryExpr())
-		sel := b.VisitSelector(selCtx)
-		if base != nil && sel != nil {
-			return &ast.SelectorExpr{
-				X:   base.(ast.Expr),
-				Sel: sel.(*ast.Ident),
-			}
-		}
+// VisitMethodExpr transforms a method expression: T.Method
+func (b *ASTBuilder) VisitMethodExpr(ctx *MethodExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
 
-	// Index (x[i])
-	if idxCtx := ctx.Index(); idxCtx != nil {
-		base := b.VisitPrimaryExpr(ctx.PrimaryExpr())
-		idx := b.VisitIndex(idxCtx)
-		if base != nil && idx != nil {
-			return &ast.IndexExpr{
-				X:      base.(ast.Expr),
-				Lbrack: b.pos(ctx),
-				Index:  idx.(ast.Expr),
-				Rbrack: b.endPos(ctx),
-			}
-		}
+	x, sel := b.visitType(ctx.Type_()), b.visitIdentifier(ctx.IDENTIFIER())
+	if x == nil || sel == nil {
+		return &ast.BadExpr{From: b.pos(ctx), To: b.endPos(ctx)}
 	}
+	return &ast.SelectorExpr{X: x, Sel: sel}
+}
 
-	// Slice (x[i:j] or x[i:j:k])
-	if sliceCtx := ctx.Slice_(); sliceCtx != nil {
-		base := b.VisitPrimaryExpr(ctx.PrimaryExpr())
-		slice := b.VisitSlice_(sliceCtx)
-		if base != nil && slice != nil {
-			sliceExpr := slice.(*ast.SliceExpr)
-			sliceExpr.X = base.(ast.Expr)
-			return sliceExpr
-		}
+// VisitSelectorExpr transforms a selector (x.y).
+func (b *ASTBuilder) VisitSelectorExpr(ctx *SelectorExprContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
 
-	// Type assertion (x.(T))
-	if assertCtx := ctx.TypeAssertion(); assertCtx != nil {
-		base := b.VisitPrimaryExpr(ctx.PrimaryExpr())
-		assert := b.VisitTypeAssertion(assertCtx)
-		if base != nil && assert != nil {
-			assertExpr := assert.(*ast.TypeAssertExpr)
-			assertExpr.X = base.(ast.Expr)
-			return assertExpr
-		}
+	x := b.visitExpr(ctx.PrimaryExpr())
+	sel, _ := b.visit(ctx.Selector()).(*ast.Ident)
+	if x == nil || sel == nil {
+		return &ast.BadExpr{From: b.pos(ctx), To: b.endPos(ctx)}
 	}
+	return &ast.SelectorExpr{X: x, Sel: sel}
+}
 
-	// Arguments (function call)
-	if argsCtx := ctx.Arguments(); argsCtx != nil {
-		base := b.VisitPrimaryExpr(ctx.PrimaryExpr())
-		args := b.VisitArguments(argsCtx)
-		if base != nil {
-			call := &ast.CallExpr{
-				Fun:    base.(ast.Expr),
-				Lparen: b.pos(ctx),
-				Rparen: b.endPos(ctx),
-			}
-			if args != nil {
-				if argList, ok := args.([]ast.Expr); ok {
-					call.Args = argList
-				}
-			}
-			return call
+// VisitIndexExpr transforms an index expression (x[i]).
+func (b *ASTBuilder) VisitIndexExpr(ctx *IndexExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	index := &ast.IndexExpr{
+		X:      b.visitExpr(ctx.PrimaryExpr()),
+		Lbrack: b.pos(ctx.Index()),
+		Index:  b.visitExpr(ctx.Index()),
+		Rbrack: b.tokenPos(ctx.GetStop()),
+	}
+
+	return index
+}
+
+// VisitSliceExpr transforms a slice expression (x[i:j] or x[i:j:k]).
+func (b *ASTBuilder) VisitSliceExpr(ctx *SliceExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	slice, ok := b.visit(ctx.Slice_()).(*ast.SliceExpr)
+	if !ok {
+		return nil
+	}
+	slice.X = b.visitExpr(ctx.PrimaryExpr())
+	return slice
+}
+
+// VisitTypeAssertionExpr transforms a type assertion (x.(T)).
+func (b *ASTBuilder) VisitTypeAssertionExpr(ctx *TypeAssertionExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	assert, ok := b.visit(ctx.TypeAssertion()).(*ast.TypeAssertExpr)
+	if !ok {
+		return nil
+	}
+	assert.X = b.visitExpr(ctx.PrimaryExpr())
+	return assert
+}
+
+// VisitCallExpr transforms a function call.
+func (b *ASTBuilder) VisitCallExpr(ctx *CallExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	call := &ast.CallExpr{
+		Fun: b.visitExpr(ctx.PrimaryExpr()),
+	}
+
+	if argsCtx, ok := ctx.Arguments().(*ArgumentsContext); ok {
+		call.Lparen = b.pos(argsCtx)
+		call.Rparen = b.tokenPos(argsCtx.GetStop())
+		if args, ok := b.VisitArguments(argsCtx).([]ast.Expr); ok {
+			call.Args = args
 		}
+		call.Ellipsis = b.terminalPos(argsCtx.GetToken(tokenEllipsis, 0))
 	}
 
-	return nil
+	return call
 }
 
 // VisitUnaryExpr transforms a unary expression.
@@ -152,60 +215,125 @@ func (b *ASTBuilder) VisitUnaryExpr(ctx *UnaryExprContext) interface{} {
 
 	// Primary expression (base case)
 	if primaryCtx := ctx.PrimaryExpr(); primaryCtx != nil {
-		return b.VisitPrimaryExpr(primaryCtx)
+		return b.visit(primaryCtx)
 	}
 
 	// Unary operator + expression
 	if unaryOpCtx := ctx.Unary_op(); unaryOpCtx != nil {
 		unary := &ast.UnaryExpr{
 			OpPos: b.pos(ctx),
+			X:     b.visitOperand(ctx.UnaryExpr(), b.endPos(unaryOpCtx)),
 		}
-
-		if op := b.VisitUnary_op(unaryOpCtx); op != nil {
-			unary.Op = op.(ast.Token)
+		if op, ok := b.visit(unaryOpCtx).(ast.Token); ok {
+			unary.Op = op
 		}
-
-		if exprCtx := ctx.Expression(); exprCtx != nil {
-			if expr := b.VisitExpression(exprCtx); expr != nil {
-				unary.X = expr.(ast.Expr)
-			}
+		if lit := b.pointerLiteral(unary); lit != nil {
+			return lit
 		}
-
 		return unary
 	}
 
 	return nil
 }
 
-// VisitOperand transforms an operand.
-func (b *ASTBuilder) VisitOperand(ctx *OperandContext) interface{} {
-	if ctx == nil {
+// pointerLiteral returns the Moxie literal node for the address of a map,
+// set or channel composite literal, &map[K]V{...}, &set[T]{...} or
+// &chan T{...}, or nil if unary is not one. The name set is predeclared
+// and always denotes the set type.
+func (b *ASTBuilder) pointerLiteral(unary *ast.UnaryExpr) ast.Expr {
+	comp, ok := unary.X.(*ast.CompositeLit)
+	if unary.Op != ast.AND || !ok {
 		return nil
 	}
 
-	// Literal
-	if litCtx := ctx.Literal(); litCtx != nil {
-		return b.VisitLiteral(litCtx)
+	switch t := comp.Type.(type) {
+	case *ast.MapType:
+		return &ast.MapLit{
+			Ampersand: unary.OpPos,
+			Map:       t.Map,
+			Lbrack:    t.Lbrack,
+			Key:       t.Key,
+			Value:     t.Value,
+			Lbrace:    comp.Lbrace,
+			Elts:      comp.Elts,
+			Rbrace:    comp.Rbrace,
+		}
+	case *ast.ChanType:
+		return b.chanLit(unary, t, comp)
+	case *ast.IndexExpr:
+		name, ok := t.X.(*ast.Ident)
+		elem, isType := t.Index.(ast.Type)
+		if ok && isType && name.Name == "set" {
+			return &ast.SetLit{
+				Ampersand: unary.OpPos,
+				Set:       name.NamePos,
+				Lbrack:    t.Lbrack,
+				Elem:      elem,
+				Lbrace:    comp.Lbrace,
+				Elts:      comp.Elts,
+				Rbrace:    comp.Rbrace,
+			}
+		}
 	}
+	return nil
+}
 
-	// Operand name (identifier)
-	if nameCtx := ctx.OperandName(); nameCtx != nil {
-		return b.VisitOperandName(nameCtx)
+// chanLit builds the channel literal &comp of channel type t. Its only
+// element is the capacity, cap: n or n; without one the channel is
+// unbuffered.
+func (b *ASTBuilder) chanLit(unary *ast.UnaryExpr, t *ast.ChanType, comp *ast.CompositeLit) *ast.ChanLit {
+	lit := &ast.ChanLit{
+		Ampersand: unary.OpPos,
+		Chan:      t.Begin,
+		Dir:       t.Dir,
+		Type:      t.Value,
+		Lbrace:    comp.Lbrace,
+		Rbrace:    comp.Rbrace,
+	}
+	if len(comp.Elts) == 0 {
+		return lit
+	}
+	if len(comp.Elts) > 1 {
+		b.errorf(comp.Elts[1].Pos(), "channel literal has more than one element; want its capacity")
+	}
+
+	lit.Cap = comp.Elts[0]
+	if kv, ok := lit.Cap.(*ast.KeyValueExpr); ok {
+		if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "cap" {
+			b.errorf(kv.Key.Pos(), "unknown channel literal field; want cap")
+		}
+		lit.Colon, lit.Cap = kv.Colon, kv.Value
 	}
+	return lit
+}
 
-	// Parenthesized expression
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		expr := b.VisitExpression(exprCtx)
-		if expr != nil {
-			return &ast.ParenExpr{
-				Lparen: b.pos(ctx),
-				X:      expr.(ast.Expr),
-				Rparen: b.endPos(ctx),
-			}
-		}
+// VisitLiteralOperand transforms a literal operand.
+func (b *ASTBuilder) VisitLiteralOperand(ctx *LiteralOperandContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.visit(ctx.Literal())
+}
+
+// VisitNameOperand transforms an operand name.
+func (b *ASTBuilder) VisitNameOperand(ctx *NameOperandContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.OperandName())
+}
 
-	return nil
+// VisitParenOperand transforms a parenthesized expression.
+func (b *ASTBuilder) VisitParenOperand(ctx *ParenOperandContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	return &ast.ParenExpr{
+		Lparen: b.pos(ctx),
+		X:      b.visitExpr(ctx.Expression()),
+		Rparen: b.tokenPos(ctx.GetStop()),
+	}
 }
 
 // VisitOperandName transforms an operand name (identifier or qualified).
@@ -216,7 +344,7 @@ func (b *ASTBuilder) VisitOperandName(ctx *OperandNameContext) interface{} {
 
 	// Qualified identifier
 	if qualCtx := ctx.QualifiedIdent(); qualCtx != nil {
-		return b.VisitQualifiedIdent(qualCtx)
+		return b.visit(qualCtx)
 	}
 
 	// Simple identifier
@@ -245,12 +373,7 @@ func (b *ASTBuilder) VisitIndex(ctx *IndexContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
-
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		return b.VisitExpression(exprCtx)
-	}
-
-	return nil
+	return b.visit(ctx.Expression())
 }
 
 // VisitSlice_ transforms a slice expression.
@@ -261,26 +384,31 @@ func (b *ASTBuilder) VisitSlice_(ctx *Slice_Context) interface{} {
 
 	slice := &ast.SliceExpr{
 		Lbrack: b.pos(ctx),
-		Rbrack: b.endPos(ctx),
+		Rbrack: b.tokenPos(ctx.GetStop()),
 	}
 
-	exprs := ctx.AllExpression()
-	if len(exprs) >= 1 && exprs[0] != nil {
-		if expr := b.VisitExpression(exprs[0]); expr != nil {
-			slice.Low = expr.(ast.Expr)
-		}
-	}
-	if len(exprs) >= 2 && exprs[1] != nil {
-		if expr := b.VisitExpression(exprs[1]); expr != nil {
-			slice.High = expr.(ast.Expr)
-		}
-	}
-	if len(exprs) >= 3 && exprs[2] != nil {
-		if expr := b.VisitExpression(exprs[2]); expr != nil {
-			slice.Max = expr.(ast.Expr)
-			slice.Slice3 = true
+	// Assign each expression to the bound it precedes: the expressions
+	// are optional, so their position relative to the colons matters.
+	colons := 0
+	for _, child := range ctx.GetChildren() {
+		switch c := child.(type) {
+		case antlr.TerminalNode:
+			if c.GetSymbol().GetTokenType() == tokenColon {
+				colons++
+			}
+		case IExpressionContext:
+			expr := b.visitExpr(c)
+			switch colons {
+			case 0:
+				slice.Low = expr
+			case 1:
+				slice.High = expr
+			default:
+				slice.Max = expr
+			}
 		}
 	}
+	slice.Slice3 = colons == 2
 
 	return slice
 }
@@ -291,18 +419,11 @@ func (b *ASTBuilder) VisitTypeAssertion(ctx *TypeAssertionContext) interface{} {
 		return nil
 	}
 
-	assert := &ast.TypeAssertExpr{
-		Lparen: b.pos(ctx),
-		Rparen: b.endPos(ctx),
-	}
-
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			assert.Type = typ.(ast.Type)
-		}
+	return &ast.TypeAssertExpr{
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Type:   b.visitType(ctx.Type_()),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
 	}
-
-	return assert
 }
 
 // VisitArguments transforms function arguments.
@@ -311,40 +432,130 @@ func (b *ASTBuilder) VisitArguments(ctx *ArgumentsContext) interface{} {
 		return nil
 	}
 
-	// Expression list or type with expression list
-	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		return b.VisitExpressionList(exprListCtx)
+	args := []ast.Expr{}
+
+	// A leading type argument, as in make(*[]T, n)
+	if typ := b.visitType(ctx.Type_()); typ != nil {
+		args = append(args, typ)
 	}
 
-	return []ast.Expr{}
+	return append(args, b.visitExprList(ctx.ExpressionList())...)
 }
 
-// VisitConversion transforms a type conversion.
-func (b *ASTBuilder) VisitConversion(ctx *ConversionContext) interface{} {
+// ============================================================================
+// Conversions
+// ============================================================================
+
+// VisitSimpleConversion transforms a type conversion T(x).
+//
+// Calls of instantiated generic functions, such as dlsym[func() int64](lib),
+// are also parsed as conversions. dlsym is built as an FFICall.
+func (b *ASTBuilder) VisitSimpleConversion(ctx *SimpleConversionContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	call := &ast.CallExpr{
-		Lparen: b.pos(ctx),
-		Rparen: b.endPos(ctx),
+	fun := b.visitType(ctx.Type_())
+	args := []ast.Expr{}
+	if expr := b.visitExpr(ctx.Expression()); expr != nil {
+		args = append(args, expr)
 	}
 
-	// Type (used as function)
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			call.Fun = typ.(ast.Expr)
+	if index, ok := fun.(*ast.IndexExpr); ok {
+		if name, ok := index.X.(*ast.Ident); ok && name.Name == "dlsym" {
+			typ, _ := index.Index.(ast.Type)
+			return &ast.FFICall{
+				Name:   name,
+				Lbrack: index.Lbrack,
+				Type:   typ,
+				Rbrack: index.Rbrack,
+				Args:   args,
+			}
 		}
 	}
 
-	// Expression to convert
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			call.Args = []ast.Expr{expr.(ast.Expr)}
+	return &ast.CallExpr{
+		Fun:    fun,
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Args:   args,
+		Rparen: b.tokenPos(ctx.GetStop()),
+	}
+}
+
+// VisitSliceCastExpr transforms a zero-copy slice cast: (*[]T)(x)
+func (b *ASTBuilder) VisitSliceCastExpr(ctx *SliceCastExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.typeCoercion(ctx, ctx.Type_(), nil, ctx.Expression())
+}
+
+// VisitSliceCastEndianExpr transforms a slice cast with byte order:
+// (*[]T, BigEndian)(x)
+func (b *ASTBuilder) VisitSliceCastEndianExpr(ctx *SliceCastEndianExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.typeCoercion(ctx, ctx.Type_(), ctx.Endianness(), ctx.Expression())
+}
+
+// VisitSliceCastCopyExpr transforms a copying slice cast: &(*[]T)(x)
+func (b *ASTBuilder) VisitSliceCastCopyExpr(ctx *SliceCastCopyExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	return &ast.UnaryExpr{
+		OpPos: b.pos(ctx),
+		Op:    ast.AND,
+		X:     b.typeCoercion(ctx, ctx.Type_(), nil, ctx.Expression()),
+	}
+}
+
+// VisitSliceCastCopyEndianExpr transforms a copying slice cast with byte
+// order: &(*[]T, LittleEndian)(x)
+func (b *ASTBuilder) VisitSliceCastCopyEndianExpr(ctx *SliceCastCopyEndianExprContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	return &ast.UnaryExpr{
+		OpPos: b.pos(ctx),
+		Op:    ast.AND,
+		X:     b.typeCoercion(ctx, ctx.Type_(), ctx.Endianness(), ctx.Expression()),
+	}
+}
+
+// tokenContext is a rule context giving access to its tokens by type. All
+// generated contexts implement it.
+type tokenContext interface {
+	antlr.ParserRuleContext
+	GetToken(ttype int, i int) antlr.TerminalNode
+}
+
+// typeCoercion builds the coercion of expr to *[]elem shared by the slice
+// cast alternatives.
+func (b *ASTBuilder) typeCoercion(ctx tokenContext, elem IType_Context, endian IEndiannessContext, expr IExpressionContext) *ast.TypeCoercion {
+	lparen := ctx.GetToken(tokenLParen, 0)
+	coercion := &ast.TypeCoercion{
+		Lparen: b.terminalPos(lparen),
+		Target: &ast.SliceType{
+			Lbrack:  b.terminalPos(ctx.GetToken(tokenLBrack, 0)),
+			Pointer: true,
+			Elem:    b.visitType(elem),
+		},
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
+		Expr:   b.visitExpr(expr),
+	}
+
+	if endian != nil {
+		coercion.Endian = &ast.Ident{
+			NamePos: b.pos(endian),
+			Name:    endian.GetText(),
 		}
 	}
 
-	return call
+	return coercion
 }
 
 // VisitExpressionList transforms an expression list.
@@ -355,8 +566,8 @@ func (b *ASTBuilder) VisitExpressionList(ctx *ExpressionListContext) interface{}
 
 	var exprs []ast.Expr
 	for _, exprCtx := range ctx.AllExpression() {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			exprs = append(exprs, expr.(ast.Expr))
+		if expr := b.visitExpr(exprCtx); expr != nil {
+			exprs = append(exprs, expr)
 		}
 	}
 
@@ -479,17 +690,17 @@ func (b *ASTBuilder) VisitLiteral(ctx *LiteralContext) interface{} {
 
 	// Basic literal
 	if basicCtx := ctx.BasicLit(); basicCtx != nil {
-		return b.VisitBasicLit(basicCtx)
+		return b.visit(basicCtx)
 	}
 
 	// Composite literal
 	if compCtx := ctx.CompositeLit(); compCtx != nil {
-		return b.VisitCompositeLit(compCtx)
+		return b.visit(compCtx)
 	}
 
 	// Function literal
 	if funcCtx := ctx.FunctionLit(); funcCtx != nil {
-		return b.VisitFunctionLit(funcCtx)
+		return b.visit(funcCtx)
 	}
 
 	return nil
@@ -519,7 +730,11 @@ func (b *ASTBuilder) VisitBasicLit(ctx *BasicLitContext) interface{} {
 		lit.Kind = ast.RuneLit
 		lit.Value = ctx.RUNE_LIT().GetText()
 	} else if strCtx := ctx.String_(); strCtx != nil {
-		if str := b.VisitString_(strCtx); str != nil {
+		// Interpolation applies to string operands, not to import paths.
+		if str := strCtx.INTERPRETED_STRING_LIT(); str != nil && strings.Contains(str.GetText(), "${") {
+			return b.interpolatedString(str.GetSymbol())
+		}
+		if str := b.visit(strCtx); str != nil {
 			return str
 		}
 	}
@@ -553,26 +768,11 @@ func (b *ASTBuilder) VisitCompositeLit(ctx *CompositeLitContext) interface{} {
 		return nil
 	}
 
-	comp := &ast.CompositeLit{
-		Lbrace: b.pos(ctx),
-		Rbrace: b.endPos(ctx),
-	}
-
-	// Literal type
-	if litTypeCtx := ctx.LiteralType(); litTypeCtx != nil {
-		if typ := b.VisitLiteralType(litTypeCtx); typ != nil {
-			comp.Type = typ.(ast.Type)
-		}
-	}
-
-	// Literal value (elements)
-	if litValCtx := ctx.LiteralValue(); litValCtx != nil {
-		if val := b.VisitLiteralValue(litValCtx); val != nil {
-			if elts, ok := val.([]ast.Expr); ok {
-				comp.Elts = elts
-			}
-		}
+	comp, ok := b.visit(ctx.LiteralValue()).(*ast.CompositeLit)
+	if !ok {
+		return nil
 	}
+	comp.Type = b.visitType(ctx.LiteralType())
 
 	return comp
 }
@@ -583,25 +783,45 @@ func (b *ASTBuilder) VisitLiteralType(ctx *LiteralTypeContext) interface{} {
 		return nil
 	}
 
-	// Struct, array, slice, map, type name, etc.
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		return b.VisitType_(typeCtx)
+	switch {
+	case ctx.StructType() != nil:
+		return b.visit(ctx.StructType())
+	case ctx.ArrayType() != nil:
+		return b.visit(ctx.ArrayType())
+	case ctx.SliceType() != nil:
+		return b.visit(ctx.SliceType())
+	case ctx.MapType() != nil:
+		return b.visit(ctx.MapType())
+	case ctx.ChannelType() != nil:
+		return b.visit(ctx.ChannelType())
+	case ctx.TypeName() != nil:
+		return b.instantiate(b.visitType(ctx.TypeName()), ctx.TypeArgs())
 	}
 
-	return nil
+	// [...]T
+	return &ast.ArrayType{
+		Lbrack: b.pos(ctx),
+		Len:    &ast.Ellipsis{Ellipsis: b.terminalPos(ctx.GetToken(tokenEllipsis, 0))},
+		Elem:   b.visitType(ctx.ElementType()),
+	}
 }
 
-// VisitLiteralValue transforms a literal value (element list).
+// VisitLiteralValue transforms a literal value. The result is a composite
+// literal without a type, which is also the form of elided nested literals.
 func (b *ASTBuilder) VisitLiteralValue(ctx *LiteralValueContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	if elemListCtx := ctx.ElementList(); elemListCtx != nil {
-		return b.VisitElementList(elemListCtx)
+	comp := &ast.CompositeLit{
+		Lbrace: b.pos(ctx),
+		Rbrace: b.tokenPos(ctx.GetStop()),
+	}
+	if elts, ok := b.visit(ctx.ElementList()).([]ast.Expr); ok {
+		comp.Elts = elts
 	}
 
-	return []ast.Expr{}
+	return comp
 }
 
 // VisitElementList transforms an element list.
@@ -612,8 +832,8 @@ func (b *ASTBuilder) VisitElementList(ctx *ElementListContext) interface{} {
 
 	var elts []ast.Expr
 	for _, keyedElemCtx := range ctx.AllKeyedElement() {
-		if elem := b.VisitKeyedElement(keyedElemCtx); elem != nil {
-			elts = append(elts, elem.(ast.Expr))
+		if elem := b.visitExpr(keyedElemCtx); elem != nil {
+			elts = append(elts, elem)
 		}
 	}
 
@@ -628,29 +848,15 @@ func (b *ASTBuilder) VisitKeyedElement(ctx *KeyedElementContext) interface{} {
 
 	// Check if it's a key:value pair
 	if keyCtx := ctx.Key(); keyCtx != nil {
-		kv := &ast.KeyValueExpr{
-			Colon: b.pos(ctx),
-		}
-
-		if key := b.VisitKey(keyCtx); key != nil {
-			kv.Key = key.(ast.Expr)
+		return &ast.KeyValueExpr{
+			Key:   b.visitExpr(keyCtx),
+			Colon: b.terminalPos(ctx.GetToken(tokenColon, 0)),
+			Value: b.visitExpr(ctx.Element()),
 		}
-
-		if elemCtx := ctx.Element(); elemCtx != nil {
-			if val := b.VisitElement(elemCtx); val != nil {
-				kv.Value = val.(ast.Expr)
-			}
-		}
-
-		return kv
 	}
 
 	// Just an element (no key)
-	if elemCtx := ctx.Element(); elemCtx != nil {
-		return b.VisitElement(elemCtx)
-	}
-
-	return nil
+	return b.visit(ctx.Element())
 }
 
 // VisitKey transforms a key in a keyed element.
@@ -659,11 +865,15 @@ func (b *ASTBuilder) VisitKey(ctx *KeyContext) interface{} {
 		return nil
 	}
 
+	if ident := ctx.IDENTIFIER(); ident != nil {
+		return b.visitIdentifier(ident)
+	}
+
 	if exprCtx := ctx.Expression(); exprCtx != nil {
-		return b.VisitExpression(exprCtx)
+		return b.visit(exprCtx)
 	}
 
-	return nil
+	return b.visit(ctx.LiteralValue())
 }
 
 // VisitElement transforms an element value.
@@ -673,14 +883,10 @@ func (b *ASTBuilder) VisitElement(ctx *ElementContext) interface{} {
 	}
 
 	if exprCtx := ctx.Expression(); exprCtx != nil {
-		return b.VisitExpression(exprCtx)
+		return b.visit(exprCtx)
 	}
 
-	if litValCtx := ctx.LiteralValue(); litValCtx != nil {
-		return b.VisitLiteralValue(litValCtx)
-	}
-
-	return nil
+	return b.visit(ctx.LiteralValue())
 }
 
 // VisitFunctionLit transforms a function literal.
@@ -689,21 +895,14 @@ func (b *ASTBuilder) VisitFunctionLit(ctx *FunctionLitContext) interface{} {
 		return nil
 	}
 
-	funcLit := &ast.FuncLit{}
-
-	// Function type
-	if funcTypeCtx := ctx.FunctionType(); funcTypeCtx != nil {
-		if funcType := b.VisitFunctionType(funcTypeCtx); funcType != nil {
-			funcLit.Type = funcType.(*ast.FuncType)
-		}
+	funcType, ok := b.visit(ctx.Signature()).(*ast.FuncType)
+	if !ok {
+		funcType = &ast.FuncType{}
 	}
+	funcType.Func = b.terminalPos(ctx.FUNC())
 
-	// Function body
-	if blockCtx := ctx.Block(); blockCtx != nil {
-		if block := b.VisitBlock(blockCtx); block != nil {
-			funcLit.Body = block.(*ast.BlockStmt)
-		}
+	return &ast.FuncLit{
+		Type: funcType,
+		Body: b.visitBlock(ctx.Block()),
 	}
-
-	return funcLit
 }
@@ -14,19 +14,11 @@ func (b *ASTBuilder) VisitBlock(ctx *BlockContext) interface{} {
 		return nil
 	}
 
-	block := &ast.BlockStmt{
+	return &ast.BlockStmt{
 		Lbrace: b.pos(ctx),
-		Rbrace: b.endPos(ctx),
+		List:   b.visitStmtList(ctx.StatementList()),
+		Rbrace: b.tokenPos(ctx.GetStop()),
 	}
-
-	// Statement list
-	if stmtListCtx := ctx.StatementList(); stmtListCtx != nil {
-		if stmts := b.VisitStatementList(stmtListCtx); stmts != nil {
-			block.List = stmts.([]ast.Stmt)
-		}
-	}
-
-	return block
 }
 
 // VisitStatementList transforms a statement list.
@@ -37,98 +29,136 @@ func (b *ASTBuilder) VisitStatementList(ctx *StatementListContext) interface{} {
 
 	var stmts []ast.Stmt
 	for _, stmtCtx := range ctx.AllStatement() {
-		if stmt := b.VisitStatement(stmtCtx); stmt != nil {
-			stmts = append(stmts, stmt.(ast.Stmt))
+		if stmt := b.visitStmt(stmtCtx); stmt != nil {
+			stmts = append(stmts, stmt)
 		}
 	}
 
 	return stmts
 }
 
-// VisitStatement transforms a statement.
-func (b *ASTBuilder) VisitStatement(ctx *StatementContext) interface{} {
+// VisitDeclStmt transforms a declaration statement.
+func (b *ASTBuilder) VisitDeclStmt(ctx *DeclStmtContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	// Declaration statement
-	if declCtx := ctx.Declaration(); declCtx != nil {
-		if decl := b.VisitDeclaration(declCtx); decl != nil {
-			return &ast.DeclStmt{Decl: decl.(ast.Decl)}
-		}
+	if decl, ok := b.visit(ctx.Declaration()).(ast.Decl); ok {
+		return &ast.DeclStmt{Decl: decl}
 	}
+	return nil
+}
 
-	// Simple statement
-	if simpleCtx := ctx.SimpleStmt(); simpleCtx != nil {
-		return b.VisitSimpleStmt(simpleCtx)
+// VisitSimpleStatement transforms a simple statement alternative.
+func (b *ASTBuilder) VisitSimpleStatement(ctx *SimpleStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.SimpleStmt())
+}
 
-	// Return statement
-	if retCtx := ctx.ReturnStmt(); retCtx != nil {
-		return b.VisitReturnStmt(retCtx)
+// VisitLabeledStatement transforms a labeled statement alternative.
+func (b *ASTBuilder) VisitLabeledStatement(ctx *LabeledStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.LabeledStmt())
+}
 
-	// Break statement
-	if breakCtx := ctx.BreakStmt(); breakCtx != nil {
-		return b.VisitBreakStmt(breakCtx)
+// VisitGoStatement transforms a go statement alternative.
+func (b *ASTBuilder) VisitGoStatement(ctx *GoStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.GoStmt())
+}
 
-	// Continue statement
-	if contCtx := ctx.ContinueStmt(); contCtx != nil {
-		return b.VisitContinueStmt(contCtx)
+// VisitReturnStatement transforms a return statement alternative.
+func (b *ASTBuilder) VisitReturnStatement(ctx *ReturnStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.ReturnStmt())
+}
 
-	// Goto statement
-	if gotoCtx := ctx.GotoStmt(); gotoCtx != nil {
-		return b.VisitGotoStmt(gotoCtx)
+// VisitBreakStatement transforms a break statement alternative.
+func (b *ASTBuilder) VisitBreakStatement(ctx *BreakStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.BreakStmt())
+}
 
-	// Fallthrough statement
-	if fallthroughCtx := ctx.FallthroughStmt(); fallthroughCtx != nil {
-		return b.VisitFallthroughStmt(fallthroughCtx)
+// VisitContinueStatement transforms a continue statement alternative.
+func (b *ASTBuilder) VisitContinueStatement(ctx *ContinueStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.ContinueStmt())
+}
 
-	// Block statement
-	if blockCtx := ctx.Block(); blockCtx != nil {
-		return b.VisitBlock(blockCtx)
+// VisitGotoStatement transforms a goto statement alternative.
+func (b *ASTBuilder) VisitGotoStatement(ctx *GotoStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.GotoStmt())
+}
 
-	// If statement
-	if ifCtx := ctx.IfStmt(); ifCtx != nil {
-		return b.VisitIfStmt(ifCtx)
+// VisitFallthroughStatement transforms a fallthrough statement alternative.
+func (b *ASTBuilder) VisitFallthroughStatement(ctx *FallthroughStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.FallthroughStmt())
+}
 
-	// Switch statement
-	if switchCtx := ctx.SwitchStmt(); switchCtx != nil {
-		return b.VisitSwitchStmt(switchCtx)
+// VisitBlockStatement transforms a block statement alternative.
+func (b *ASTBuilder) VisitBlockStatement(ctx *BlockStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.Block())
+}
 
-	// Select statement
-	if selectCtx := ctx.SelectStmt(); selectCtx != nil {
-		return b.VisitSelectStmt(selectCtx)
+// VisitIfStatement transforms an if statement alternative.
+func (b *ASTBuilder) VisitIfStatement(ctx *IfStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.IfStmt())
+}
 
-	// For statement
-	if forCtx := ctx.ForStmt(); forCtx != nil {
-		return b.VisitForStmt(forCtx)
+// VisitSwitchStatement transforms a switch statement alternative.
+func (b *ASTBuilder) VisitSwitchStatement(ctx *SwitchStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.SwitchStmt())
+}
 
-	// Defer statement
-	if deferCtx := ctx.DeferStmt(); deferCtx != nil {
-		return b.VisitDeferStmt(deferCtx)
+// VisitSelectStatement transforms a select statement alternative.
+func (b *ASTBuilder) VisitSelectStatement(ctx *SelectStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.SelectStmt())
+}
 
-	// Go statement
-	if goCtx := ctx.GoStmt(); goCtx != nil {
-		return b.VisitGoStmt(goCtx)
+// VisitForStatement transforms a for statement alternative.
+func (b *ASTBuilder) VisitForStatement(ctx *ForStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.visit(ctx.ForStmt())
+}
 
-	// Labeled statement
-	if labeledCtx := ctx.LabeledStmt(); labeledCtx != nil {
-		return b.VisitLabeledStmt(labeledCtx)
+// VisitDeferStatement transforms a defer statement alternative.
+func (b *ASTBuilder) VisitDeferStatement(ctx *DeferStatementContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
-
-	return &ast.EmptyStmt{Semicolon: b.pos(ctx)}
+	return b.visit(ctx.DeferStmt())
 }
 
 // VisitSimpleStmt transforms a simple statement.
@@ -139,27 +169,27 @@ func (b *ASTBuilder) VisitSimpleStmt(ctx *SimpleStmtContext) interface{} {
 
 	// Expression statement
 	if exprCtx := ctx.ExpressionStmt(); exprCtx != nil {
-		return b.VisitExpressionStmt(exprCtx)
+		return b.visit(exprCtx)
 	}
 
 	// Send statement
 	if sendCtx := ctx.SendStmt(); sendCtx != nil {
-		return b.VisitSendStmt(sendCtx)
+		return b.visit(sendCtx)
 	}
 
 	// Inc/Dec statement
 	if incDecCtx := ctx.IncDecStmt(); incDecCtx != nil {
-		return b.VisitIncDecStmt(incDecCtx)
+		return b.visit(incDecCtx)
 	}
 
 	// Assignment
 	if assignCtx := ctx.Assignment(); assignCtx != nil {
-		return b.VisitAssignment(assignCtx)
+		return b.visit(assignCtx)
 	}
 
 	// Short var declaration
 	if shortVarCtx := ctx.ShortVarDecl(); shortVarCtx != nil {
-		return b.VisitShortVarDecl(shortVarCtx)
+		return b.visit(shortVarCtx)
 	}
 
 	return nil
@@ -171,10 +201,8 @@ func (b *ASTBuilder) VisitExpressionStmt(ctx *ExpressionStmtContext) interface{}
 		return nil
 	}
 
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			return &ast.ExprStmt{X: expr.(ast.Expr)}
-		}
+	if expr := b.visitExpr(ctx.Expression()); expr != nil {
+		return &ast.ExprStmt{X: expr}
 	}
 
 	return nil
@@ -187,18 +215,14 @@ func (b *ASTBuilder) VisitSendStmt(ctx *SendStmtContext) interface{} {
 	}
 
 	send := &ast.SendStmt{
-		Arrow: b.pos(ctx),
+		Arrow: b.terminalPos(ctx.GetToken(tokenArrow, 0)),
 	}
 
-	// Channel expression
+	// Channel and value expressions
 	exprs := ctx.AllExpression()
 	if len(exprs) >= 2 {
-		if ch := b.VisitExpression(exprs[0]); ch != nil {
-			send.Chan = ch.(ast.Expr)
-		}
-		if val := b.VisitExpression(exprs[1]); val != nil {
-			send.Value = val.(ast.Expr)
-		}
+		send.Chan = b.visitExpr(exprs[0])
+		send.Value = b.visitExpr(exprs[1])
 	}
 
 	return send
@@ -211,23 +235,16 @@ func (b *ASTBuilder) VisitIncDecStmt(ctx *IncDecStmtContext) interface{} {
 	}
 
 	incDec := &ast.IncDecStmt{
-		TokPos: b.pos(ctx),
-	}
-
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			incDec.X = expr.(ast.Expr)
-		}
+		X:   b.visitExpr(ctx.Expression()),
+		Tok: ast.INC,
 	}
 
 	// Determine if ++ or --
-	text := ctx.GetText()
-	if len(text) >= 2 {
-		if text[len(text)-2:] == "++" {
-			incDec.Tok = ast.INC
-		} else {
-			incDec.Tok = ast.DEC
-		}
+	if op := ctx.GetToken(tokenInc, 0); op != nil {
+		incDec.TokPos = b.terminalPos(op)
+	} else {
+		incDec.TokPos = b.terminalPos(ctx.GetToken(tokenDec, 0))
+		incDec.Tok = ast.DEC
 	}
 
 	return incDec
@@ -240,28 +257,16 @@ func (b *ASTBuilder) VisitAssignment(ctx *AssignmentContext) interface{} {
 	}
 
 	assign := &ast.AssignStmt{
-		TokPos: b.pos(ctx),
-		Tok:    ast.ASSIGN,
-	}
-
-	// Left-hand side
-	if lhsCtx := ctx.ExpressionList(0); lhsCtx != nil {
-		if lhs := b.VisitExpressionList(lhsCtx); lhs != nil {
-			assign.Lhs = lhs.([]ast.Expr)
-		}
+		Lhs: b.visitExprList(ctx.ExpressionList(0)),
+		Tok: ast.ASSIGN,
+		Rhs: b.visitExprList(ctx.ExpressionList(1)),
 	}
 
 	// Assignment operator
 	if opCtx := ctx.Assign_op(); opCtx != nil {
-		if op := b.VisitAssign_op(opCtx); op != nil {
-			assign.Tok = op.(ast.Token)
-		}
-	}
-
-	// Right-hand side
-	if rhsCtx := ctx.ExpressionList(1); rhsCtx != nil {
-		if rhs := b.VisitExpressionList(rhsCtx); rhs != nil {
-			assign.Rhs = rhs.([]ast.Expr)
+		assign.TokPos = b.pos(opCtx)
+		if op, ok := b.visit(opCtx).(ast.Token); ok {
+			assign.Tok = op
 		}
 	}
 
@@ -311,27 +316,22 @@ func (b *ASTBuilder) VisitShortVarDecl(ctx *ShortVarDeclContext) interface{} {
 		return nil
 	}
 
-	assign := &ast.AssignStmt{
-		TokPos: b.pos(ctx),
+	return &ast.AssignStmt{
+		Lhs:    identExprs(b.visitIdentifierList(ctx.IdentifierList())),
+		TokPos: b.terminalPos(ctx.GetToken(tokenDefine, 0)),
 		Tok:    ast.DEFINE,
+		Rhs:    b.visitExprList(ctx.ExpressionList()),
 	}
+}
 
-	// Left-hand side (identifiers)
-	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
-		idents := b.visitIdentifierList(idListCtx)
-		for _, id := range idents {
-			assign.Lhs = append(assign.Lhs, id)
-		}
-	}
-
-	// Right-hand side (expressions)
-	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		if exprs := b.VisitExpressionList(exprListCtx); exprs != nil {
-			assign.Rhs = exprs.([]ast.Expr)
-		}
+// identExprs converts identifiers to expressions for the left-hand side of
+// a definition.
+func identExprs(idents []*ast.Ident) []ast.Expr {
+	var exprs []ast.Expr
+	for _, id := range idents {
+		exprs = append(exprs, id)
 	}
-
-	return assign
+	return exprs
 }
 
 // VisitReturnStmt transforms a return statement.
@@ -340,18 +340,10 @@ func (b *ASTBuilder) VisitReturnStmt(ctx *ReturnStmtContext) interface{} {
 		return nil
 	}
 
-	ret := &ast.ReturnStmt{
-		Return: b.tokenPos(ctx.RETURN().GetSymbol()),
-	}
-
-	// Return values
-	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		if exprs := b.VisitExpressionList(exprListCtx); exprs != nil {
-			ret.Results = exprs.([]ast.Expr)
-		}
+	return &ast.ReturnStmt{
+		Return:  b.terminalPos(ctx.RETURN()),
+		Results: b.visitExprList(ctx.ExpressionList()),
 	}
-
-	return ret
 }
 
 // VisitBreakStmt transforms a break statement.
@@ -360,17 +352,11 @@ func (b *ASTBuilder) VisitBreakStmt(ctx *BreakStmtContext) interface{} {
 		return nil
 	}
 
-	branch := &ast.BranchStmt{
-		TokPos: b.tokenPos(ctx.BREAK().GetSymbol()),
+	return &ast.BranchStmt{
+		TokPos: b.terminalPos(ctx.BREAK()),
 		Tok:    ast.BREAK,
+		Label:  b.visitIdentifier(ctx.IDENTIFIER()),
 	}
-
-	// Label (optional)
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		branch.Label = b.visitIdentifier(ident)
-	}
-
-	return branch
 }
 
 // VisitContinueStmt transforms a continue statement.
@@ -379,17 +365,11 @@ func (b *ASTBuilder) VisitContinueStmt(ctx *ContinueStmtContext) interface{} {
 		return nil
 	}
 
-	branch := &ast.BranchStmt{
-		TokPos: b.tokenPos(ctx.CONTINUE().GetSymbol()),
+	return &ast.BranchStmt{
+		TokPos: b.terminalPos(ctx.CONTINUE()),
 		Tok:    ast.CONTINUE,
+		Label:  b.visitIdentifier(ctx.IDENTIFIER()),
 	}
-
-	// Label (optional)
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		branch.Label = b.visitIdentifier(ident)
-	}
-
-	return branch
 }
 
 // VisitGotoStmt transforms a goto statement.
@@ -398,17 +378,11 @@ func (b *ASTBuilder) VisitGotoStmt(ctx *GotoStmtContext) interface{} {
 		return nil
 	}
 
-	branch := &ast.BranchStmt{
-		TokPos: b.tokenPos(ctx.GOTO().GetSymbol()),
+	return &ast.BranchStmt{
+		TokPos: b.terminalPos(ctx.GOTO()),
 		Tok:    ast.GOTO,
+		Label:  b.visitIdentifier(ctx.IDENTIFIER()),
 	}
-
-	// Label (required)
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		branch.Label = b.visitIdentifier(ident)
-	}
-
-	return branch
 }
 
 // VisitFallthroughStmt transforms a fallthrough statement.
@@ -418,7 +392,7 @@ func (b *ASTBuilder) VisitFallthroughStmt(ctx *FallthroughStmtContext) interface
 	}
 
 	return &ast.BranchStmt{
-		TokPos: b.tokenPos(ctx.FALLTHROUGH().GetSymbol()),
+		TokPos: b.terminalPos(ctx.FALLTHROUGH()),
 		Tok:    ast.FALLTHROUGH,
 	}
 }
@@ -430,15 +404,13 @@ func (b *ASTBuilder) VisitDeferStmt(ctx *DeferStmtContext) interface{} {
 	}
 
 	deferStmt := &ast.DeferStmt{
-		Defer: b.tokenPos(ctx.DEFER().GetSymbol()),
+		Defer: b.terminalPos(ctx.DEFER()),
 	}
 
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			if call, ok := expr.(*ast.CallExpr); ok {
-				deferStmt.Call = call
-			}
-		}
+	if call, ok := b.visitExpr(ctx.Expression()).(*ast.CallExpr); ok {
+		deferStmt.Call = call
+	} else {
+		b.errorf(b.pos(ctx), "expression in defer must be function call")
 	}
 
 	return deferStmt
@@ -451,15 +423,13 @@ func (b *ASTBuilder) VisitGoStmt(ctx *GoStmtContext) interface{} {
 	}
 
 	goStmt := &ast.GoStmt{
-		Go: b.tokenPos(ctx.GO().GetSymbol()),
+		Go: b.terminalPos(ctx.GO()),
 	}
 
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			if call, ok := expr.(*ast.CallExpr); ok {
-				goStmt.Call = call
-			}
-		}
+	if call, ok := b.visitExpr(ctx.Expression()).(*ast.CallExpr); ok {
+		goStmt.Call = call
+	} else {
+		b.errorf(b.pos(ctx), "expression in go must be function call")
 	}
 
 	return goStmt
@@ -471,23 +441,11 @@ func (b *ASTBuilder) VisitLabeledStmt(ctx *LabeledStmtContext) interface{} {
 		return nil
 	}
 
-	labeled := &ast.LabeledStmt{
-		Colon: b.pos(ctx),
-	}
-
-	// Label
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		labeled.Label = b.visitIdentifier(ident)
-	}
-
-	// Statement
-	if stmtCtx := ctx.Statement(); stmtCtx != nil {
-		if stmt := b.VisitStatement(stmtCtx); stmt != nil {
-			labeled.Stmt = stmt.(ast.Stmt)
-		}
+	return &ast.LabeledStmt{
+		Label: b.visitIdentifier(ctx.IDENTIFIER()),
+		Colon: b.terminalPos(ctx.GetToken(tokenColon, 0)),
+		Stmt:  b.visitStmt(ctx.Statement()),
 	}
-
-	return labeled
 }
 
 // VisitIfStmt transforms an if statement.
@@ -497,186 +455,374 @@ func (b *ASTBuilder) VisitIfStmt(ctx *IfStmtContext) interface{} {
 	}
 
 	ifStmt := &ast.IfStmt{
-		If: b.tokenPos(ctx.IF().GetSymbol()),
-	}
-
-	// Initialization statement (optional)
-	if simpleCtx := ctx.SimpleStmt(); simpleCtx != nil {
-		if stmt := b.VisitSimpleStmt(simpleCtx); stmt != nil {
-			ifStmt.Init = stmt.(ast.Stmt)
-		}
-	}
-
-	// Condition
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			ifStmt.Cond = expr.(ast.Expr)
-		}
+		If:   b.terminalPos(ctx.IF()),
+		Init: b.visitStmt(ctx.SimpleStmt()),
+		Cond: b.visitExpr(ctx.Expression()),
 	}
 
 	// Body
 	blocks := ctx.AllBlock()
 	if len(blocks) >= 1 {
-		if block := b.VisitBlock(blocks[0]); block != nil {
-			ifStmt.Body = block.(*ast.BlockStmt)
-		}
+		ifStmt.Body = b.visitBlock(blocks[0])
 	}
 
 	// Else branch
 	if len(blocks) >= 2 {
-		if block := b.VisitBlock(blocks[1]); block != nil {
-			ifStmt.Else = block.(*ast.BlockStmt)
-		}
+		ifStmt.Else = b.visitBlock(blocks[1])
 	} else if elseIfCtx := ctx.IfStmt(); elseIfCtx != nil {
-		if elseIf := b.VisitIfStmt(elseIfCtx); elseIf != nil {
-			ifStmt.Else = elseIf.(ast.Stmt)
-		}
+		ifStmt.Else = b.visitStmt(elseIfCtx)
 	}
 
 	return ifStmt
 }
 
-// VisitForStmt transforms a for statement.
-func (b *ASTBuilder) VisitForStmt(ctx *ForStmtContext) interface{} {
+// VisitSwitchStmt transforms a switch statement.
+func (b *ASTBuilder) VisitSwitchStmt(ctx *SwitchStmtContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	forStmt := &ast.ForStmt{
-		For: b.tokenPos(ctx.FOR().GetSymbol()),
+	if exprCtx := ctx.ExprSwitchStmt(); exprCtx != nil {
+		return b.visit(exprCtx)
 	}
 
-	// For clause (init; cond; post)
-	if clauseCtx := ctx.ForClause(); clauseCtx != nil {
-		if clause := b.VisitForClause(clauseCtx); clause != nil {
-			if fs, ok := clause.(*ast.ForStmt); ok {
-				forStmt.Init = fs.Init
-				forStmt.Cond = fs.Cond
-				forStmt.Post = fs.Post
-			}
-		}
+	if typeCtx := ctx.TypeSwitchStmt(); typeCtx != nil {
+		return b.visit(typeCtx)
 	}
 
-	// Range clause
-	if rangeCtx := ctx.RangeClause(); rangeCtx != nil {
-		if rangeStmt := b.VisitRangeClause(rangeCtx); rangeStmt != nil {
-			// Return range statement instead
-			if rs, ok := rangeStmt.(*ast.RangeStmt); ok {
-				rs.For = forStmt.For
-				if blockCtx := ctx.Block(); blockCtx != nil {
-					if block := b.VisitBlock(blockCtx); block != nil {
-						rs.Body = block.(*ast.BlockStmt)
-					}
-				}
-				return rs
-			}
-		}
+	return nil
+}
+
+// VisitExprSwitchStmt transforms an expression switch statement.
+func (b *ASTBuilder) VisitExprSwitchStmt(ctx *ExprSwitchStmtContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
 
-	// Simple condition (infinite loop if nil)
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			forStmt.Cond = expr.(ast.Expr)
-		}
+	switchStmt := &ast.SwitchStmt{
+		Switch: b.terminalPos(ctx.SWITCH()),
+		Init:   b.visitStmt(ctx.SimpleStmt()),
+		Tag:    b.visitExpr(ctx.Expression()),
+		Body: &ast.BlockStmt{
+			Lbrace: b.terminalPos(ctx.GetToken(tokenLBrace, 0)),
+			Rbrace: b.terminalPos(ctx.GetToken(tokenRBrace, 0)),
+		},
 	}
 
-	// Body
-	if blockCtx := ctx.Block(); blockCtx != nil {
-		if block := b.VisitBlock(blockCtx); block != nil {
-			forStmt.Body = block.(*ast.BlockStmt)
+	// Case clauses
+	for _, clauseCtx := range ctx.AllExprCaseClause() {
+		if clause := b.visitStmt(clauseCtx); clause != nil {
+			switchStmt.Body.List = append(switchStmt.Body.List, clause)
 		}
 	}
 
-	return forStmt
+	return switchStmt
 }
 
-// VisitForClause transforms a for clause.
-func (b *ASTBuilder) VisitForClause(ctx *ForClauseContext) interface{} {
+// VisitExprCaseClause transforms a case clause of an expression switch.
+func (b *ASTBuilder) VisitExprCaseClause(ctx *ExprCaseClauseContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	forStmt := &ast.ForStmt{}
+	clause, ok := b.visit(ctx.ExprSwitchCase()).(*ast.CaseClause)
+	if !ok {
+		return nil
+	}
+	clause.Colon = b.terminalPos(ctx.GetToken(tokenColon, 0))
+	clause.Body = b.visitStmtList(ctx.StatementList())
+
+	return clause
+}
+
+// VisitExprSwitchCase transforms "case x, y" or "default".
+func (b *ASTBuilder) VisitExprSwitchCase(ctx *ExprSwitchCaseContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	if def := ctx.DEFAULT(); def != nil {
+		return &ast.CaseClause{Case: b.terminalPos(def)}
+	}
+
+	return &ast.CaseClause{
+		Case: b.terminalPos(ctx.CASE()),
+		List: b.visitExprList(ctx.ExpressionList()),
+	}
+}
+
+// VisitTypeSwitchStmt transforms a type switch statement.
+func (b *ASTBuilder) VisitTypeSwitchStmt(ctx *TypeSwitchStmtContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	switchStmt := &ast.TypeSwitchStmt{
+		Switch: b.terminalPos(ctx.SWITCH()),
+		Init:   b.visitStmt(ctx.SimpleStmt()),
+		Assign: b.visitStmt(ctx.TypeSwitchGuard()),
+		Body: &ast.BlockStmt{
+			Lbrace: b.terminalPos(ctx.GetToken(tokenLBrace, 0)),
+			Rbrace: b.terminalPos(ctx.GetToken(tokenRBrace, 0)),
+		},
+	}
 
-	// Init, cond, post
-	stmts := ctx.AllSimpleStmt()
-	if len(stmts) >= 1 && stmts[0] != nil {
-		if stmt := b.VisitSimpleStmt(stmts[0]); stmt != nil {
-			forStmt.Init = stmt.(ast.Stmt)
+	// Case clauses
+	for _, clauseCtx := range ctx.AllTypeCaseClause() {
+		if clause := b.visitStmt(clauseCtx); clause != nil {
+			switchStmt.Body.List = append(switchStmt.Body.List, clause)
 		}
 	}
-	if len(stmts) >= 2 && stmts[1] != nil {
-		if stmt := b.VisitSimpleStmt(stmts[1]); stmt != nil {
-			forStmt.Post = stmt.(ast.Stmt)
+
+	return switchStmt
+}
+
+// VisitTypeSwitchGuard transforms "x := y.(type)" or "y.(type)". The
+// result is an AssignStmt or ExprStmt holding a TypeAssertExpr with a nil
+// Type.
+func (b *ASTBuilder) VisitTypeSwitchGuard(ctx *TypeSwitchGuardContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	assert := &ast.TypeAssertExpr{
+		X:      b.visitExpr(ctx.PrimaryExpr()),
+		Lparen: b.terminalPos(ctx.GetToken(tokenLParen, 0)),
+		Rparen: b.terminalPos(ctx.GetToken(tokenRParen, 0)),
+	}
+
+	if ident := ctx.IDENTIFIER(); ident != nil {
+		return &ast.AssignStmt{
+			Lhs:    []ast.Expr{b.visitIdentifier(ident)},
+			TokPos: b.terminalPos(ctx.GetToken(tokenDefine, 0)),
+			Tok:    ast.DEFINE,
+			Rhs:    []ast.Expr{assert},
 		}
 	}
 
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		if expr := b.VisitExpression(exprCtx); expr != nil {
-			forStmt.Cond = expr.(ast.Expr)
+	return &ast.ExprStmt{X: assert}
+}
+
+// VisitTypeCaseClause transforms a case clause of a type switch.
+func (b *ASTBuilder) VisitTypeCaseClause(ctx *TypeCaseClauseContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	clause, ok := b.visit(ctx.TypeSwitchCase()).(*ast.CaseClause)
+	if !ok {
+		return nil
+	}
+	clause.Colon = b.terminalPos(ctx.GetToken(tokenColon, 0))
+	clause.Body = b.visitStmtList(ctx.StatementList())
+
+	return clause
+}
+
+// VisitTypeSwitchCase transforms "case T1, T2" or "default".
+func (b *ASTBuilder) VisitTypeSwitchCase(ctx *TypeSwitchCaseContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	if def := ctx.DEFAULT(); def != nil {
+		return &ast.CaseClause{Case: b.terminalPos(def)}
+	}
+
+	clause := &ast.CaseClause{
+		Case: b.terminalPos(ctx.CASE()),
+	}
+	if types, ok := b.visit(ctx.TypeList()).([]ast.Expr); ok {
+		clause.List = types
+	}
+
+	return clause
+}
+
+// VisitSelectStmt transforms a select statement.
+func (b *ASTBuilder) VisitSelectStmt(ctx *SelectStmtContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	selectStmt := &ast.SelectStmt{
+		Select: b.terminalPos(ctx.SELECT()),
+		Body: &ast.BlockStmt{
+			Lbrace: b.terminalPos(ctx.GetToken(tokenLBrace, 0)),
+			Rbrace: b.terminalPos(ctx.GetToken(tokenRBrace, 0)),
+		},
+	}
+
+	// Communication clauses
+	for _, clauseCtx := range ctx.AllCommClause() {
+		if clause := b.visitStmt(clauseCtx); clause != nil {
+			selectStmt.Body.List = append(selectStmt.Body.List, clause)
 		}
 	}
 
-	return forStmt
+	return selectStmt
 }
 
-// VisitRangeClause transforms a range clause.
-func (b *ASTBuilder) VisitRangeClause(ctx *RangeClauseContext) interface{} {
+// VisitCommClause transforms a communication clause of a select statement.
+func (b *ASTBuilder) VisitCommClause(ctx *CommClauseContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	rangeStmt := &ast.RangeStmt{
-		TokPos: b.pos(ctx),
-		Tok:    ast.ASSIGN,
+	clause, ok := b.visit(ctx.CommCase()).(*ast.CommClause)
+	if !ok {
+		return nil
 	}
+	clause.Colon = b.terminalPos(ctx.GetToken(tokenColon, 0))
+	clause.Body = b.visitStmtList(ctx.StatementList())
 
-	// Check if it's a short var decl (:=)
-	if ctx.GetDefine() != nil {
-		rangeStmt.Tok = ast.DEFINE
+	return clause
+}
+
+// VisitCommCase transforms "case send-or-receive" or "default".
+func (b *ASTBuilder) VisitCommCase(ctx *CommCaseContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
 
-	// Key and value
-	exprs := ctx.AllExpression()
-	if len(exprs) >= 1 {
-		if expr := b.VisitExpression(exprs[0]); expr != nil {
-			rangeStmt.Key = expr.(ast.Expr)
+	if def := ctx.DEFAULT(); def != nil {
+		return &ast.CommClause{Case: b.terminalPos(def)}
+	}
+
+	clause := &ast.CommClause{
+		Case: b.terminalPos(ctx.CASE()),
+	}
+	if sendCtx := ctx.SendStmt(); sendCtx != nil {
+		clause.Comm = b.visitStmt(sendCtx)
+	} else {
+		clause.Comm = b.visitStmt(ctx.RecvStmt())
+	}
+
+	return clause
+}
+
+// VisitRecvStmt transforms the receive operation of a select case:
+// "v = <-ch", "v, ok := <-ch" or "<-ch".
+func (b *ASTBuilder) VisitRecvStmt(ctx *RecvStmtContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	recv := b.visitExpr(ctx.Expression())
+
+	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
+		return &ast.AssignStmt{
+			Lhs:    b.visitExprList(exprListCtx),
+			TokPos: b.terminalPos(ctx.GetToken(tokenAssign, 0)),
+			Tok:    ast.ASSIGN,
+			Rhs:    []ast.Expr{recv},
 		}
 	}
-	if len(exprs) >= 2 {
-		if expr := b.VisitExpression(exprs[1]); expr != nil {
-			rangeStmt.Value = expr.(ast.Expr)
+
+	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
+		return &ast.AssignStmt{
+			Lhs:    identExprs(b.visitIdentifierList(idListCtx)),
+			TokPos: b.terminalPos(ctx.GetToken(tokenDefine, 0)),
+			Tok:    ast.DEFINE,
+			Rhs:    []ast.Expr{recv},
 		}
 	}
 
-	// Range expression
-	if len(exprs) >= 3 {
-		if expr := b.VisitExpression(exprs[2]); expr != nil {
-			rangeStmt.X = expr.(ast.Expr)
+	return &ast.ExprStmt{X: recv}
+}
+
+// VisitForStmt transforms a for statement.
+func (b *ASTBuilder) VisitForStmt(ctx *ForStmtContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	forPos := b.terminalPos(ctx.FOR())
+	body := b.visitBlock(ctx.Block())
+
+	// Range clause
+	if rangeCtx := ctx.RangeClause(); rangeCtx != nil {
+		if rs, ok := b.visit(rangeCtx).(*ast.RangeStmt); ok {
+			rs.For = forPos
+			rs.Body = body
+			return rs
 		}
-	} else if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
-		if exprs := b.VisitExpressionList(exprListCtx); exprs != nil {
-			exprList := exprs.([]ast.Expr)
-			if len(exprList) > 0 {
-				rangeStmt.X = exprList[len(exprList)-1]
-			}
+	}
+
+	forStmt := &ast.ForStmt{
+		For:  forPos,
+		Body: body,
+	}
+
+	// For clause (init; cond; post)
+	if clauseCtx := ctx.ForClause(); clauseCtx != nil {
+		if fs, ok := b.visit(clauseCtx).(*ast.ForStmt); ok {
+			forStmt.Init = fs.Init
+			forStmt.Cond = fs.Cond
+			forStmt.Post = fs.Post
 		}
 	}
 
-	return rangeStmt
+	// Simple condition (infinite loop if nil)
+	if exprCtx := ctx.Expression(); exprCtx != nil {
+		forStmt.Cond = b.visitExpr(exprCtx)
+	}
+
+	return forStmt
 }
 
-// Placeholder stubs for switch and select (can be expanded later)
+// VisitForClause transforms a for clause.
+func (b *ASTBuilder) VisitForClause(ctx *ForClauseContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
 
-func (b *ASTBuilder) VisitSwitchStmt(ctx *SwitchStmtContext) interface{} {
-	// Simplified: return a basic switch statement
-	return &ast.SwitchStmt{
-		Switch: b.pos(ctx),
+	forStmt := &ast.ForStmt{
+		Cond: b.visitExpr(ctx.Expression()),
+	}
+
+	// Init and post statements are told apart by their position relative
+	// to the first semicolon, since either may be omitted.
+	semi := ctx.GetToken(tokenSemicolon, 0)
+	for _, stmtCtx := range ctx.AllSimpleStmt() {
+		stmt := b.visitStmt(stmtCtx)
+		if semi != nil && stmtCtx.GetStart().GetStart() < semi.GetSymbol().GetStart() {
+			forStmt.Init = stmt
+		} else {
+			forStmt.Post = stmt
+		}
 	}
+
+	return forStmt
 }
 
-func (b *ASTBuilder) VisitSelectStmt(ctx *SelectStmtContext) interface{} {
-	return &ast.SelectStmt{
-		Select: b.pos(ctx),
+// VisitRangeClause transforms a range clause.
+func (b *ASTBuilder) VisitRangeClause(ctx *RangeClauseContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	rangeStmt := &ast.RangeStmt{
+		X: b.visitExpr(ctx.Expression()),
 	}
+
+	// Key and value, assigned (=) or defined (:=)
+	var lhs []ast.Expr
+	if exprListCtx := ctx.ExpressionList(); exprListCtx != nil {
+		lhs = b.visitExprList(exprListCtx)
+		rangeStmt.TokPos = b.terminalPos(ctx.GetToken(tokenAssign, 0))
+		rangeStmt.Tok = ast.ASSIGN
+	} else if idListCtx := ctx.IdentifierList(); idListCtx != nil {
+		lhs = identExprs(b.visitIdentifierList(idListCtx))
+		rangeStmt.TokPos = b.terminalPos(ctx.GetToken(tokenDefine, 0))
+		rangeStmt.Tok = ast.DEFINE
+	}
+	if len(lhs) >= 1 {
+		rangeStmt.Key = lhs[0]
+	}
+	if len(lhs) >= 2 {
+		rangeStmt.Value = lhs[1]
+	}
+
+	return rangeStmt
 }
@@ -0,0 +1,139 @@
+package antlr
+
+import (
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// String interpolation
+//
+// An interpreted string literal may embed expressions as ${expr}; the lexer
+// reads it as an ordinary string literal and the builder parses the
+// expressions. The rules are:
+//
+//   - $${ is a literal ${, and \x24 a literal $ that never starts an
+//     interpolation. Any other $ is literal text.
+//   - An expression ends at the first } outside braces, raw strings and
+//     rune literals it contains, so ${m[k]}, ${func() int { return 1 }()}
+//     and ${strings.Join(xs, `, `)} work.
+//   - An expression cannot contain an interpreted string literal, whose
+//     quote would end the enclosing one; use a raw string instead.
+
+// interpolatedString builds an interpreted string literal token containing
+// ${ as an InterpolatedString.
+func (b *ASTBuilder) interpolatedString(tok antlr.Token) ast.Expr {
+	lit := &ast.InterpolatedString{
+		ValuePos: b.tokenPos(tok),
+		Value:    tok.GetText(),
+	}
+
+	text := []rune(tok.GetText())
+	end := len(text) - 1 // Closing quote
+	var seg strings.Builder
+	for i := 1; i < end; {
+		switch {
+		case text[i] == '\\' && i+1 < end:
+			seg.WriteString(string(text[i : i+2]))
+			i += 2
+		case hasRunePrefix(text[i:end], "$${"):
+			seg.WriteString("${")
+			i += 3
+		case hasRunePrefix(text[i:end], "${"):
+			close := interpolationEnd(text[:end], i+2)
+			if close < 0 {
+				b.errorf(b.tokenPos(tok).Advance(string(text[:i])), "unterminated ${ in string literal")
+				seg.WriteString(string(text[i:end]))
+				i = end
+				continue
+			}
+			lit.Text = append(lit.Text, seg.String())
+			lit.Exprs = append(lit.Exprs, b.interpolation(tok, text, i+2, close))
+			seg.Reset()
+			i = close + 1
+		default:
+			seg.WriteRune(text[i])
+			i++
+		}
+	}
+	lit.Text = append(lit.Text, seg.String())
+
+	return lit
+}
+
+// interpolationEnd returns the index of the } closing the interpolation
+// whose expression starts at text[start], or -1 if it is not closed.
+func interpolationEnd(text []rune, start int) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case '`', '\'':
+			quote := text[i]
+			for i++; i < len(text) && text[i] != quote; i++ {
+				if quote == '\'' && text[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// interpolation parses the expression text[start:stop] of the string
+// literal tok. The expression is scanned in place, so its positions are
+// those in the source file.
+func (b *ASTBuilder) interpolation(tok antlr.Token, text []rune, start, stop int) ast.Expr {
+	pos := b.tokenPos(tok).Advance(string(text[:start]))
+	if strings.TrimSpace(string(text[start:stop])) == "" {
+		b.errorf(pos, "empty expression in string interpolation")
+		return &ast.BadExpr{From: pos, To: pos}
+	}
+
+	index := tok.GetStart() + start
+	line, col := advance(tok.GetLine(), tok.GetColumn(), text[:start])
+	input := tok.GetInputStream()
+
+	collector := NewErrorCollector(b.filename)
+	lexer := NewScanner(antlr.NewInputStream(input.GetText(0, index+stop-start-1)))
+	lexer.seek(index, line, col)
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(collector)
+
+	parser := NewMoxieParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(collector)
+	parser.AddErrorListener(lexer.ErrorListener())
+
+	tree := parser.Expression()
+	if next := parser.GetTokenStream().LT(1); next.GetTokenType() != antlr.TokenEOF && len(collector.Diagnostics()) == 0 {
+		b.errorf(b.tokenPos(next), "unexpected %s in string interpolation", next.GetText())
+	}
+	for _, d := range collector.Diagnostics() {
+		b.errorf(d.Pos, "%s", d.Message)
+	}
+
+	expr := b.visitExpr(tree)
+	if expr == nil {
+		return &ast.BadExpr{From: pos, To: pos.Advance(string(text[start:stop]))}
+	}
+	return expr
+}
+
+// hasRunePrefix reports whether text begins with prefix.
+func hasRunePrefix(text []rune, prefix string) bool {
+	for _, r := range prefix {
+		if len(text) == 0 || text[0] != r {
+			return false
+		}
+		text = text[1:]
+	}
+	return true
+}
@@ -0,0 +1,620 @@
+package antlr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// parseBody parses src as the body of func f and returns its statements.
+func parseBody(t *testing.T, body string) []ast.Stmt {
+	t.Helper()
+
+	src := "package main\n\nfunc f() {\n" + body + "\n}\n"
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	if len(file.Decls) != 1 {
+		t.Fatalf("expected 1 declaration, got %d", len(file.Decls))
+	}
+	fn, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok || fn.Body == nil {
+		t.Fatalf("expected function with body, got %T", file.Decls[0])
+	}
+	return fn.Body.List
+}
+
+func TestBuildExprSwitch(t *testing.T) {
+	stmts := parseBody(t, `	switch x := g(); x {
+	case 1, 2:
+		h()
+		fallthrough
+	case 3:
+	default:
+		h()
+	}`)
+
+	sw, ok := stmts[0].(*ast.SwitchStmt)
+	if !ok {
+		t.Fatalf("expected *ast.SwitchStmt, got %T", stmts[0])
+	}
+	if _, ok := sw.Init.(*ast.AssignStmt); !ok {
+		t.Errorf("expected init statement, got %T", sw.Init)
+	}
+	if tag, ok := sw.Tag.(*ast.Ident); !ok || tag.Name != "x" {
+		t.Errorf("expected tag x, got %#v", sw.Tag)
+	}
+	if len(sw.Body.List) != 3 {
+		t.Fatalf("expected 3 clauses, got %d", len(sw.Body.List))
+	}
+
+	first := sw.Body.List[0].(*ast.CaseClause)
+	if len(first.List) != 2 || len(first.Body) != 2 {
+		t.Errorf("first clause: got %d values and %d statements", len(first.List), len(first.Body))
+	}
+	if br, ok := first.Body[1].(*ast.BranchStmt); !ok || br.Tok != ast.FALLTHROUGH {
+		t.Errorf("expected fallthrough, got %#v", first.Body[1])
+	}
+	if first.Colon.Line != first.Case.Line || first.Colon.Column <= first.Case.Column {
+		t.Errorf("colon position %v not after case %v", first.Colon, first.Case)
+	}
+
+	if second := sw.Body.List[1].(*ast.CaseClause); len(second.Body) != 0 {
+		t.Errorf("expected empty second clause, got %d statements", len(second.Body))
+	}
+	if def := sw.Body.List[2].(*ast.CaseClause); def.List != nil || len(def.Body) != 1 {
+		t.Errorf("default clause: got %d values and %d statements", len(def.List), len(def.Body))
+	}
+}
+
+func TestBuildTypeSwitch(t *testing.T) {
+	stmts := parseBody(t, `	switch v := x.(type) {
+	case int32, *Point:
+	case nil:
+	default:
+	}
+	switch x.(type) {
+	}`)
+
+	sw, ok := stmts[0].(*ast.TypeSwitchStmt)
+	if !ok {
+		t.Fatalf("expected *ast.TypeSwitchStmt, got %T", stmts[0])
+	}
+	assign, ok := sw.Assign.(*ast.AssignStmt)
+	if !ok || assign.Tok != ast.DEFINE {
+		t.Fatalf("expected v := x.(type), got %#v", sw.Assign)
+	}
+	if assert, ok := assign.Rhs[0].(*ast.TypeAssertExpr); !ok || assert.Type != nil {
+		t.Errorf("expected x.(type), got %#v", assign.Rhs[0])
+	}
+	if len(sw.Body.List) != 3 {
+		t.Fatalf("expected 3 clauses, got %d", len(sw.Body.List))
+	}
+	first := sw.Body.List[0].(*ast.CaseClause)
+	if len(first.List) != 2 {
+		t.Fatalf("expected 2 types, got %d", len(first.List))
+	}
+	if _, ok := first.List[1].(*ast.PointerType); !ok {
+		t.Errorf("expected *Point, got %T", first.List[1])
+	}
+
+	bare, ok := stmts[1].(*ast.TypeSwitchStmt)
+	if !ok {
+		t.Fatalf("expected *ast.TypeSwitchStmt, got %T", stmts[1])
+	}
+	if _, ok := bare.Assign.(*ast.ExprStmt); !ok {
+		t.Errorf("expected x.(type) guard without assignment, got %T", bare.Assign)
+	}
+}
+
+func TestBuildSelect(t *testing.T) {
+	stmts := parseBody(t, `	select {
+	case v := <-in:
+		h(v)
+	case v, ok = <-in:
+	case out <- 1:
+	case <-done:
+		return
+	default:
+	}`)
+
+	sel, ok := stmts[0].(*ast.SelectStmt)
+	if !ok {
+		t.Fatalf("expected *ast.SelectStmt, got %T", stmts[0])
+	}
+	if len(sel.Body.List) != 5 {
+		t.Fatalf("expected 5 clauses, got %d", len(sel.Body.List))
+	}
+
+	clauses := make([]*ast.CommClause, len(sel.Body.List))
+	for i, stmt := range sel.Body.List {
+		clauses[i] = stmt.(*ast.CommClause)
+	}
+	if assign, ok := clauses[0].Comm.(*ast.AssignStmt); !ok || assign.Tok != ast.DEFINE || len(clauses[0].Body) != 1 {
+		t.Errorf("clause 0: expected v := <-in with body, got %#v", clauses[0])
+	}
+	if assign, ok := clauses[1].Comm.(*ast.AssignStmt); !ok || assign.Tok != ast.ASSIGN || len(assign.Lhs) != 2 {
+		t.Errorf("clause 1: expected v, ok = <-in, got %#v", clauses[1].Comm)
+	}
+	if _, ok := clauses[2].Comm.(*ast.SendStmt); !ok {
+		t.Errorf("clause 2: expected send, got %T", clauses[2].Comm)
+	}
+	if _, ok := clauses[3].Comm.(*ast.ExprStmt); !ok {
+		t.Errorf("clause 3: expected receive expression, got %T", clauses[3].Comm)
+	}
+	if clauses[4].Comm != nil {
+		t.Errorf("default clause: expected nil Comm, got %T", clauses[4].Comm)
+	}
+}
+
+func TestParseFileBuildError(t *testing.T) {
+	_, diags := ParseFile("test.mx", "package main\n\nfunc f() {\n\tgo x\n}\n")
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d: %v", len(diags), diags)
+	}
+	if d := diags[0]; d.Code != diag.BuildError || d.Pos.Line != 4 {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+}
+
+// TestParseFileRecovery checks that a syntax error does not hide the
+// errors and declarations after it.
+func TestParseFileRecovery(t *testing.T) {
+	src := `package main
+
+func f() {
+	x := g(1,
+	y := 2
+}
+
+func h() {
+	if x {
+		return
+}
+
+func m() {
+	return )
+}
+
+var v = 1
+`
+	file, diags := ParseFile("test.mx", src)
+	want := []struct {
+		line, col int
+		msg       string
+	}{
+		{5, 4, "':='"},
+		{13, 1, "missing '}' before function declaration"},
+		{14, 9, "')'"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(want), len(diags), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.Code != diag.SyntaxError || d.Pos.Line != w.line || d.Pos.Column != w.col || !strings.Contains(d.Message, w.msg) {
+			t.Errorf("diagnostic %d: got %v, want %d:%d %s", i, d, w.line, w.col, w.msg)
+		}
+	}
+
+	var names []string
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			names = append(names, d.Name.Name)
+		case *ast.VarDecl:
+			names = append(names, d.Specs[0].Names[0].Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "f h m v" {
+		t.Errorf("declarations %q, want \"f h m v\"", got)
+	}
+}
+
+// TestParseFileRecoveryDecl checks that an unclosed bracket is closed
+// before a declaration that cannot be nested in it, or that follows a
+// syntax error.
+func TestParseFileRecoveryDecl(t *testing.T) {
+	tests := []struct {
+		src, msg string
+		line     int
+		names    string
+	}{
+		{`package main
+
+type T struct {
+	a int32
+
+var v = 1
+
+func d() {}
+`, "missing '}' before var declaration", 6, "T v d"},
+		{`package main
+
+func f() {
+	g(1 +)
+	if x {
+}
+
+const c = 1
+
+func (T) m() {}
+`, "missing '}' before const declaration", 8, "f c m"},
+	}
+	for _, test := range tests {
+		file, diags := ParseFile("test.mx", test.src)
+		if len(diags) == 0 || len(diags) > 2 {
+			t.Fatalf("expected 1 or 2 diagnostics, got %d:\n%v", len(diags), diags)
+		}
+		if d := diags[len(diags)-1]; d.Code != diag.SyntaxError || d.Pos.Line != test.line || d.Pos.Column != 1 ||
+			d.Message != test.msg {
+			t.Errorf("got %v", d)
+		}
+
+		var names []string
+		for _, d := range file.Decls {
+			switch d := d.(type) {
+			case *ast.TypeDecl:
+				names = append(names, d.Specs[0].Name.Name)
+			case *ast.ConstDecl:
+				names = append(names, d.Specs[0].Names[0].Name)
+			case *ast.VarDecl:
+				names = append(names, d.Specs[0].Names[0].Name)
+			case *ast.FuncDecl:
+				names = append(names, d.Name.Name)
+			}
+		}
+		if got := strings.Join(names, " "); got != test.names {
+			t.Errorf("declarations %q, want %q", got, test.names)
+		}
+	}
+}
+
+// TestParseFileUnindented checks that declarations and function literals
+// at the start of a line inside a function parse without errors.
+func TestParseFileUnindented(t *testing.T) {
+	src := `package main
+
+func f() {
+var x = 1
+const c = 2
+type T int32
+g(
+func() {},
+)
+func() {
+println(x, c)
+}()
+switch y := v.(type) {
+}
+}
+
+func (T) m() {}
+`
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	if len(file.Decls) != 2 {
+		t.Fatalf("expected 2 declarations, got %d", len(file.Decls))
+	}
+	if n := len(file.Decls[0].(*ast.FuncDecl).Body.List); n != 6 {
+		t.Errorf("expected 6 statements in f, got %d", n)
+	}
+}
+
+func TestBuildPositions(t *testing.T) {
+	src := "package main\n\nfunc f() {\n\ts := \"héllo\"; g(s[1:], ä)\n\tif x := <-ch; x != nil {\n\t\tx++\n\t}\n}\n"
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+
+	fn := file.Decls[0].(*ast.FuncDecl)
+	assign := fn.Body.List[0].(*ast.AssignStmt)
+	call := fn.Body.List[1].(*ast.ExprStmt).X.(*ast.CallExpr)
+	slice := call.Args[0].(*ast.SliceExpr)
+	ifStmt := fn.Body.List[2].(*ast.IfStmt)
+	cond := ifStmt.Cond.(*ast.BinaryExpr)
+	incDec := ifStmt.Body.List[0].(*ast.IncDecStmt)
+
+	tests := []struct {
+		name string
+		pos  ast.Position
+		want string
+	}{
+		{"func", fn.Type.Func, "func"},
+		{"lbrace", fn.Body.Lbrace, "{"},
+		{"define", assign.TokPos, ":="},
+		{"call lparen", call.Lparen, "("},
+		{"slice lbrack", slice.Lbrack, "["},
+		{"slice rbrack", slice.Rbrack, "]"},
+		{"argument after non-ASCII", call.Args[1].Pos(), "ä"},
+		{"call rparen", call.Rparen, ")"},
+		{"if", ifStmt.If, "if"},
+		{"operator", cond.OpPos, "!="},
+		{"increment", incDec.TokPos, "++"},
+		{"rbrace", fn.Body.Rbrace, "}"},
+	}
+	for _, tt := range tests {
+		if !strings.HasPrefix(src[tt.pos.Offset:], tt.want) {
+			t.Errorf("%s: offset %d points at %q", tt.name, tt.pos.Offset, src[tt.pos.Offset:tt.pos.Offset+len(tt.want)])
+		}
+		lineStart := strings.LastIndexByte(src[:tt.pos.Offset], '\n') + 1
+		if col := tt.pos.Offset - lineStart + 1; tt.pos.Column != col {
+			t.Errorf("%s: column %d, want %d", tt.name, tt.pos.Column, col)
+		}
+	}
+
+	// End positions are immediately after the node.
+	if end := call.End(); src[end.Offset-1] != ')' {
+		t.Errorf("call ends at offset %d, after %q", end.Offset, src[end.Offset-1])
+	}
+	if end := fn.Body.End(); end.Offset != len(src)-1 {
+		t.Errorf("body ends at offset %d, want %d", end.Offset, len(src)-1)
+	}
+	if end := file.End(); end.Offset != len(src)-1 {
+		t.Errorf("file ends at offset %d, want %d", end.Offset, len(src)-1)
+	}
+}
+
+func TestBuildPointerLiterals(t *testing.T) {
+	src := `package main
+
+var m = &map[string]*set[int32]{"a": &set[int32]{1, 2}}
+var s = &set[string]{}
+var g = &List[int32]{}
+`
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	value := func(i int) ast.Expr { return file.Decls[i].(*ast.VarDecl).Specs[0].Values[0] }
+
+	m, ok := value(0).(*ast.MapLit)
+	if !ok {
+		t.Fatalf("expected *ast.MapLit, got %T", value(0))
+	}
+	if len(m.Elts) != 1 || src[m.Pos().Offset] != '&' || src[m.End().Offset-1] != '}' {
+		t.Errorf("unexpected map literal %+v", m)
+	}
+	inner, ok := m.Elts[0].(*ast.KeyValueExpr).Value.(*ast.SetLit)
+	if !ok || len(inner.Elts) != 2 {
+		t.Errorf("expected nested *ast.SetLit with 2 elements, got %T", m.Elts[0].(*ast.KeyValueExpr).Value)
+	}
+
+	if s, ok := value(1).(*ast.SetLit); !ok || s.Elem.(*ast.Ident).Name != "string" || !strings.HasPrefix(src[s.Set.Offset:], "set[") {
+		t.Errorf("expected *ast.SetLit of string, got %#v", value(1))
+	}
+
+	// Other generic types stay composite literals.
+	if u, ok := value(2).(*ast.UnaryExpr); !ok || u.Op != ast.AND {
+		t.Errorf("expected &CompositeLit, got %T", value(2))
+	}
+}
+
+func TestBuildConstType(t *testing.T) {
+	src := `package main
+
+func f(p const *[]byte, q *const []byte) {}
+`
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	params := file.Decls[0].(*ast.FuncDecl).Type.Params.List
+
+	c, ok := params[0].Type.(*ast.ConstType)
+	if !ok {
+		t.Fatalf("expected *ast.ConstType, got %T", params[0].Type)
+	}
+	if _, ok := c.Type.(*ast.PointerType); !ok || !strings.HasPrefix(src[c.Pos().Offset:], "const *") || c.End() != c.Type.End() {
+		t.Errorf("unexpected const type %#v", c)
+	}
+
+	if p, ok := params[1].Type.(*ast.PointerType); !ok {
+		t.Errorf("expected *ast.PointerType, got %T", params[1].Type)
+	} else if _, ok := p.Base.(*ast.ConstType); !ok {
+		t.Errorf("expected pointer to *ast.ConstType, got %T", p.Base)
+	}
+}
+
+func TestBuildChanLit(t *testing.T) {
+	src := `package main
+
+func f(n int32) {
+	g(&chan int32{}, &chan<- int32{n * 2}, &<-chan string{cap: 1}, S{c: &chan bool{1}})
+}
+`
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	call := file.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+	field := call.Args[3].(*ast.CompositeLit).Elts[0].(*ast.KeyValueExpr).Value
+
+	tests := []struct {
+		lit   ast.Expr
+		dir   ast.ChanDir
+		cap   string
+		keyed bool
+	}{
+		{call.Args[0], ast.ChanBoth, "", false},
+		{call.Args[1], ast.ChanSend, "n * 2", false},
+		{call.Args[2], ast.ChanRecv, "1", true},
+		{field, ast.ChanBoth, "1", false},
+	}
+	for i, tt := range tests {
+		lit, ok := tt.lit.(*ast.ChanLit)
+		if !ok {
+			t.Errorf("literal %d: expected *ast.ChanLit, got %T", i, tt.lit)
+			continue
+		}
+		if lit.Dir != tt.dir || lit.Colon.IsValid() != tt.keyed || src[lit.End().Offset-1] != '}' {
+			t.Errorf("literal %d: unexpected %+v", i, lit)
+		}
+		capacity := ""
+		if lit.Cap != nil {
+			capacity = src[lit.Cap.Pos().Offset:lit.Cap.End().Offset]
+		}
+		if capacity != tt.cap {
+			t.Errorf("literal %d: capacity %q, want %q", i, capacity, tt.cap)
+		}
+	}
+
+	_, diags = ParseFile("test.mx", "package main\n\nvar c = &chan int32{size: 1}\nvar d = &chan int32{1, 2}\n")
+	if len(diags) != 2 || diags[0].Pos.Line != 3 || diags[1].Pos.Line != 4 {
+		t.Errorf("expected errors on lines 3 and 4, got %v", diags)
+	}
+}
+
+func TestBuildInterpolatedString(t *testing.T) {
+	src := "package main\n\nvar s = \"héllo ${name}, ${m[`}`] + f('}')}% $${x} \\x24{y}\"\n"
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+
+	lit, ok := file.Decls[0].(*ast.VarDecl).Specs[0].Values[0].(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("expected *ast.InterpolatedString, got %T", file.Decls[0].(*ast.VarDecl).Specs[0].Values[0])
+	}
+	wantText := []string{"héllo ", ", ", "% ${x} \\x24{y}"}
+	if len(lit.Text) != len(wantText) || len(lit.Exprs) != 2 {
+		t.Fatalf("got text %q and %d expressions", lit.Text, len(lit.Exprs))
+	}
+	for i, want := range wantText {
+		if lit.Text[i] != want {
+			t.Errorf("text %d: got %q, want %q", i, lit.Text[i], want)
+		}
+	}
+	if lit.End().Offset != len(src)-1 {
+		t.Errorf("literal ends at offset %d, want %d", lit.End().Offset, len(src)-1)
+	}
+
+	// The expressions have their positions in the file.
+	for i, want := range []string{"name", "m[`}`] + f('}')"} {
+		x := lit.Exprs[i]
+		if got := src[x.Pos().Offset:x.End().Offset]; got != want {
+			t.Errorf("expression %d spans %q, want %q", i, got, want)
+		}
+	}
+	if _, ok := lit.Exprs[1].(*ast.BinaryExpr); !ok {
+		t.Errorf("expected *ast.BinaryExpr, got %T", lit.Exprs[1])
+	}
+
+	// Import paths are never interpolated.
+	file, _ = ParseFile("test.mx", "package main\n\nimport \"a${b}\"\n")
+	if path := file.Imports[0].Specs[0].Path; path == nil || path.Value != `"a${b}"` {
+		t.Errorf("unexpected import path %v", path)
+	}
+}
+
+func TestBuildInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		col  int
+	}{
+		{`"${a"`, "unterminated ${", 10},
+		{`"x${ }"`, "empty expression", 13},
+		{`"${a b}"`, "unexpected b", 14},
+	}
+	for _, tt := range tests {
+		_, diags := ParseFile("test.mx", "package main\n\nvar s = "+tt.src+"\n")
+		if len(diags) != 1 {
+			t.Errorf("%s: expected 1 diagnostic, got %v", tt.src, diags)
+			continue
+		}
+		if d := diags[0]; !strings.Contains(d.Message, tt.want) || d.Pos.Line != 3 || d.Pos.Column != tt.col {
+			t.Errorf("%s: unexpected diagnostic %v", tt.src, d)
+		}
+	}
+}
+
+func TestBuildComments(t *testing.T) {
+	src := `// Package main is a test.
+package main
+
+// Doc for x.
+var x int // line comment for x
+
+/* Point is a point. */
+type Point struct {
+	// X is the abscissa.
+	X int32 // in pixels
+	Y int32
+}
+
+// f does nothing
+// at all.
+func f() {
+	// leading
+	g() // trailing
+
+	// detached
+
+	h()
+}
+`
+	file, diags := ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+	if len(file.Comments) != 10 {
+		t.Fatalf("expected 10 comment groups, got %d", len(file.Comments))
+	}
+	for _, g := range file.Comments {
+		for _, c := range g.List {
+			if !strings.HasPrefix(src[c.Slash.Offset:], c.Text) {
+				t.Errorf("comment %q at offset %d", c.Text, c.Slash.Offset)
+			}
+		}
+	}
+
+	text := func(g *ast.CommentGroup) string { return strings.TrimSpace(g.Text()) }
+	if got := text(file.Doc); got != "// Package main is a test." {
+		t.Errorf("file doc %q", got)
+	}
+
+	varDecl := file.Decls[0].(*ast.VarDecl)
+	if got := text(varDecl.Doc); got != "// Doc for x." {
+		t.Errorf("var doc %q", got)
+	}
+	if spec := varDecl.Specs[0]; spec.Doc != nil || text(spec.Comment) != "// line comment for x" {
+		t.Errorf("var spec doc %q, comment %q", text(spec.Doc), text(spec.Comment))
+	}
+
+	typeDecl := file.Decls[1].(*ast.TypeDecl)
+	if got := text(typeDecl.Doc); got != "/* Point is a point. */" {
+		t.Errorf("type doc %q", got)
+	}
+	fields := typeDecl.Specs[0].Type.(*ast.StructType).Fields.List
+	if text(fields[0].Doc) != "// X is the abscissa." || text(fields[0].Comment) != "// in pixels" {
+		t.Errorf("field X doc %q, comment %q", text(fields[0].Doc), text(fields[0].Comment))
+	}
+	if fields[1].Doc != nil || fields[1].Comment != nil {
+		t.Errorf("field Y has comments")
+	}
+
+	fn := file.Decls[2].(*ast.FuncDecl)
+	if fn.Doc == nil || len(fn.Doc.List) != 2 {
+		t.Fatalf("expected 2-line func doc, got %#v", fn.Doc)
+	}
+
+	// Statements are reached through a comment map.
+	cmap := ast.NewCommentMap(file, file.Comments)
+	g, h := fn.Body.List[0], fn.Body.List[1]
+	if groups := cmap[g]; len(groups) != 2 || text(groups[0]) != "// leading" || text(groups[1]) != "// trailing" {
+		t.Errorf("comments for g(): %d groups", len(groups))
+	}
+	if groups := cmap[h]; len(groups) != 1 || text(groups[0]) != "// detached" {
+		t.Errorf("comments for h(): %d groups", len(groups))
+	}
+	if got := len(cmap.Comments()); got != len(file.Comments) {
+		t.Errorf("comment map has %d groups, want %d", got, len(file.Comments))
+	}
+}
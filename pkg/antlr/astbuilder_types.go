@@ -1,6 +1,7 @@
 package antlr
 
 import (
+	"github.com/antlr4-go/antlr/v4"
 	"github.com/mleku/moxie/pkg/ast"
 )
 
@@ -8,46 +9,55 @@ import (
 // Type Expressions
 // ============================================================================
 
-// VisitType_ transforms a type expression.
-func (b *ASTBuilder) VisitType_(ctx *Type_Context) interface{} {
+// VisitNamedType transforms a named type, possibly instantiated with type
+// arguments (List[T]).
+func (b *ASTBuilder) VisitNamedType(ctx *NamedTypeContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
+	return b.instantiate(b.visitType(ctx.TypeName()), ctx.TypeArgs())
+}
 
-	// Named type (identifier or qualified identifier)
-	if namedCtx, ok := ctx.(*NamedTypeContext); ok {
-		return b.VisitNamedType(namedCtx)
-	}
-
-	// Type literal (struct, interface, array, slice, map, chan, func, pointer)
-	if litCtx, ok := ctx.(*TypeLiteralContext); ok {
-		return b.VisitTypeLiteral(litCtx)
+// instantiate applies type arguments to a generic type name. Without
+// arguments the name is returned unchanged.
+func (b *ASTBuilder) instantiate(name ast.Type, argsCtx ITypeArgsContext) ast.Type {
+	args, ok := argsCtx.(*TypeArgsContext)
+	if !ok || name == nil {
+		return name
 	}
 
-	// Parenthesized type
-	if parenCtx, ok := ctx.(*ParenTypeContext); ok {
-		return b.VisitParenType(parenCtx)
+	lbrack := b.pos(args)
+	rbrack := b.tokenPos(args.GetStop())
+	types, _ := b.VisitTypeArgs(args).([]ast.Expr)
+	if len(types) == 1 {
+		return &ast.IndexExpr{X: name, Lbrack: lbrack, Index: types[0], Rbrack: rbrack}
 	}
+	return &ast.IndexListExpr{X: name, Lbrack: lbrack, Indices: types, Rbrack: rbrack}
+}
 
-	// Const type (Moxie feature)
-	if constCtx, ok := ctx.(*ConstTypeContext); ok {
-		return b.VisitConstType(constCtx)
+// VisitTypeArgs transforms type arguments into a list of types.
+func (b *ASTBuilder) VisitTypeArgs(ctx *TypeArgsContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
-
-	return nil
+	return b.visit(ctx.TypeList())
 }
 
-// VisitNamedType transforms a named type (identifier or qualified).
-func (b *ASTBuilder) VisitNamedType(ctx *NamedTypeContext) interface{} {
+// VisitTypeList transforms a list of types (type arguments or the types of
+// a type switch case).
+func (b *ASTBuilder) VisitTypeList(ctx *TypeListContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
-	if typeNameCtx := ctx.TypeName(); typeNameCtx != nil {
-		return b.VisitTypeName(typeNameCtx)
+	var types []ast.Expr
+	for _, typeCtx := range ctx.AllType_() {
+		if typ := b.visitType(typeCtx); typ != nil {
+			types = append(types, typ)
+		}
 	}
 
-	return nil
+	return types
 }
 
 // VisitTypeName transforms a type name.
@@ -56,14 +66,16 @@ func (b *ASTBuilder) VisitTypeName(ctx *TypeNameContext) interface{} {
 		return nil
 	}
 
-	// Check for qualified identifier (package.Type)
-	if qualCtx := ctx.QualifiedIdent(); qualCtx != nil {
-		return b.VisitQualifiedIdent(qualCtx)
-	}
-
-	// Simple identifier
-	if ident := ctx.IDENTIFIER(); ident != nil {
-		return b.visitIdentifier(ident)
+	idents := ctx.AllIDENTIFIER()
+	switch len(idents) {
+	case 1:
+		return b.visitIdentifier(idents[0])
+	case 2:
+		// Qualified identifier (package.Type)
+		return &ast.SelectorExpr{
+			X:   b.visitIdentifier(idents[0]),
+			Sel: b.visitIdentifier(idents[1]),
+		}
 	}
 
 	return nil
@@ -74,12 +86,7 @@ func (b *ASTBuilder) VisitTypeLiteral(ctx *TypeLiteralContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
-
-	if litCtx := ctx.TypeLit(); litCtx != nil {
-		return b.VisitTypeLit(litCtx)
-	}
-
-	return nil
+	return b.visit(ctx.TypeLit())
 }
 
 // VisitTypeLit transforms a type literal (struct, interface, array, etc.).
@@ -89,35 +96,35 @@ func (b *ASTBuilder) VisitTypeLit(ctx *TypeLitContext) interface{} {
 	}
 
 	if arrayCtx := ctx.ArrayType(); arrayCtx != nil {
-		return b.VisitArrayType(arrayCtx)
+		return b.visit(arrayCtx)
 	}
 
 	if structCtx := ctx.StructType(); structCtx != nil {
-		return b.VisitStructType(structCtx)
+		return b.visit(structCtx)
 	}
 
 	if ptrCtx := ctx.PointerType(); ptrCtx != nil {
-		return b.VisitPointerType(ptrCtx)
+		return b.visit(ptrCtx)
 	}
 
 	if funcCtx := ctx.FunctionType(); funcCtx != nil {
-		return b.VisitFunctionType(funcCtx)
+		return b.visit(funcCtx)
 	}
 
 	if ifaceCtx := ctx.InterfaceType(); ifaceCtx != nil {
-		return b.VisitInterfaceType(ifaceCtx)
+		return b.visit(ifaceCtx)
 	}
 
 	if sliceCtx := ctx.SliceType(); sliceCtx != nil {
-		return b.VisitSliceType(sliceCtx)
+		return b.visit(sliceCtx)
 	}
 
 	if mapCtx := ctx.MapType(); mapCtx != nil {
-		return b.VisitMapType(mapCtx)
+		return b.visit(mapCtx)
 	}
 
 	if chanCtx := ctx.ChannelType(); chanCtx != nil {
-		return b.VisitChannelType(chanCtx)
+		return b.visit(chanCtx)
 	}
 
 	return nil
@@ -129,18 +136,11 @@ func (b *ASTBuilder) VisitParenType(ctx *ParenTypeContext) interface{} {
 		return nil
 	}
 
-	paren := &ast.ParenType{
+	return &ast.ParenType{
 		Lparen: b.pos(ctx),
-		Rparen: b.endPos(ctx),
-	}
-
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			paren.X = typ.(ast.Type)
-		}
+		X:      b.visitType(ctx.Type_()),
+		Rparen: b.tokenPos(ctx.GetStop()),
 	}
-
-	return paren
 }
 
 // VisitPointerType transforms a pointer type.
@@ -149,17 +149,14 @@ func (b *ASTBuilder) VisitPointerType(ctx *PointerTypeContext) interface{} {
 		return nil
 	}
 
-	ptr := &ast.PointerType{
-		Star: b.pos(ctx),
+	base := b.visitType(ctx.Type_())
+	if base == nil {
+		return nil
 	}
-
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			ptr.Base = typ.(ast.Type)
-		}
+	return &ast.PointerType{
+		Star: b.pos(ctx),
+		Base: base,
 	}
-
-	return ptr
 }
 
 // VisitSliceType transforms a slice type.
@@ -168,17 +165,15 @@ func (b *ASTBuilder) VisitSliceType(ctx *SliceTypeContext) interface{} {
 		return nil
 	}
 
-	slice := &ast.SliceType{
-		Lbrack: b.pos(ctx),
+	elem := b.visitType(ctx.ElementType())
+	if elem == nil {
+		return nil
 	}
-
-	if elemCtx := ctx.ElementType(); elemCtx != nil {
-		if elem := b.VisitElementType(elemCtx); elem != nil {
-			slice.Elem = elem.(ast.Type)
-		}
+	return &ast.SliceType{
+		Lbrack:  b.terminalPos(ctx.GetToken(tokenLBrack, 0)),
+		Pointer: ctx.GetToken(tokenStar, 0) != nil,
+		Elem:    elem,
 	}
-
-	return slice
 }
 
 // VisitElementType transforms an element type.
@@ -186,12 +181,7 @@ func (b *ASTBuilder) VisitElementType(ctx *ElementTypeContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
-
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		return b.VisitType_(typeCtx)
-	}
-
-	return nil
+	return b.visit(ctx.Type_())
 }
 
 // VisitArrayType transforms an array type.
@@ -200,23 +190,15 @@ func (b *ASTBuilder) VisitArrayType(ctx *ArrayTypeContext) interface{} {
 		return nil
 	}
 
-	array := &ast.ArrayType{
-		Lbrack: b.pos(ctx),
-	}
-
-	if lenCtx := ctx.ArrayLength(); lenCtx != nil {
-		if length := b.VisitArrayLength(lenCtx); length != nil {
-			array.Len = length.(ast.Expr)
-		}
+	elem := b.visitType(ctx.ElementType())
+	if elem == nil {
+		return nil
 	}
-
-	if elemCtx := ctx.ElementType(); elemCtx != nil {
-		if elem := b.VisitElementType(elemCtx); elem != nil {
-			array.Elem = elem.(ast.Type)
-		}
+	return &ast.ArrayType{
+		Lbrack: b.pos(ctx),
+		Len:    b.visitExpr(ctx.ArrayLength()),
+		Elem:   elem,
 	}
-
-	return array
 }
 
 // VisitArrayLength transforms an array length expression.
@@ -224,12 +206,7 @@ func (b *ASTBuilder) VisitArrayLength(ctx *ArrayLengthContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
-
-	if exprCtx := ctx.Expression(); exprCtx != nil {
-		return b.VisitExpression(exprCtx)
-	}
-
-	return nil
+	return b.visit(ctx.Expression())
 }
 
 // VisitStructType transforms a struct type.
@@ -238,20 +215,22 @@ func (b *ASTBuilder) VisitStructType(ctx *StructTypeContext) interface{} {
 		return nil
 	}
 
+	lbrace := b.terminalPos(ctx.GetToken(tokenLBrace, 0))
+	rbrace := b.terminalPos(ctx.GetToken(tokenRBrace, 0))
 	structType := &ast.StructType{
-		Struct: b.pos(ctx),
-		Lbrace: b.pos(ctx),
-		Rbrace: b.endPos(ctx),
+		Struct: b.terminalPos(ctx.STRUCT()),
+		Lbrace: lbrace,
+		Rbrace: rbrace,
 		Fields: &ast.FieldList{
-			Opening: b.pos(ctx),
-			Closing: b.endPos(ctx),
+			Opening: lbrace,
+			Closing: rbrace,
 		},
 	}
 
 	// Add fields
 	for _, fieldCtx := range ctx.AllFieldDecl() {
-		if field := b.VisitFieldDecl(fieldCtx); field != nil {
-			structType.Fields.List = append(structType.Fields.List, field.(*ast.Field))
+		if field, ok := b.visit(fieldCtx).(*ast.Field); ok {
+			structType.Fields.List = append(structType.Fields.List, field)
 		}
 	}
 
@@ -269,23 +248,38 @@ func (b *ASTBuilder) VisitFieldDecl(ctx *FieldDeclContext) interface{} {
 	// Field names (if present)
 	if idListCtx := ctx.IdentifierList(); idListCtx != nil {
 		field.Names = b.visitIdentifierList(idListCtx)
+		field.Type = b.visitType(ctx.Type_())
+	} else {
+		// Embedded field
+		field.Type = b.visitType(ctx.EmbeddedField())
 	}
 
-	// Field type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			field.Type = typ.(ast.Type)
-		}
+	// Field tag (if present)
+	if tag, ok := b.visit(ctx.Tag_()).(*ast.BasicLit); ok {
+		field.Tag = tag
 	}
 
-	// Field tag (if present)
-	if tagCtx := ctx.Tag_(); tagCtx != nil {
-		if tag := b.VisitTag_(tagCtx); tag != nil {
-			field.Tag = tag.(*ast.BasicLit)
+	return field
+}
+
+// VisitEmbeddedField transforms an embedded struct field: T or *T.
+func (b *ASTBuilder) VisitEmbeddedField(ctx *EmbeddedFieldContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	name := b.visitType(ctx.TypeName())
+	if name == nil {
+		return nil
+	}
+	if star := ctx.GetToken(tokenStar, 0); star != nil {
+		return &ast.PointerType{
+			Star: b.terminalPos(star),
+			Base: name,
 		}
 	}
 
-	return field
+	return name
 }
 
 // VisitTag_ transforms a struct field tag.
@@ -294,11 +288,11 @@ func (b *ASTBuilder) VisitTag_(ctx *Tag_Context) interface{} {
 		return nil
 	}
 
-	if str := ctx.String_(); str != nil {
-		return b.VisitString_(str)
+	return &ast.BasicLit{
+		ValuePos: b.pos(ctx),
+		Kind:     ast.StringLit,
+		Value:    ctx.GetText(),
 	}
-
-	return nil
 }
 
 // VisitInterfaceType transforms an interface type.
@@ -307,20 +301,22 @@ func (b *ASTBuilder) VisitInterfaceType(ctx *InterfaceTypeContext) interface{} {
 		return nil
 	}
 
+	lbrace := b.terminalPos(ctx.GetToken(tokenLBrace, 0))
+	rbrace := b.terminalPos(ctx.GetToken(tokenRBrace, 0))
 	iface := &ast.InterfaceType{
-		Interface: b.pos(ctx),
-		Lbrace:    b.pos(ctx),
-		Rbrace:    b.endPos(ctx),
-		Methods:   &ast.FieldList{
-			Opening: b.pos(ctx),
-			Closing: b.endPos(ctx),
+		Interface: b.terminalPos(ctx.INTERFACE()),
+		Lbrace:    lbrace,
+		Rbrace:    rbrace,
+		Methods: &ast.FieldList{
+			Opening: lbrace,
+			Closing: rbrace,
 		},
 	}
 
 	// Add interface elements (methods and embedded types)
 	for _, elemCtx := range ctx.AllInterfaceElem() {
-		if elem := b.VisitInterfaceElem(elemCtx); elem != nil {
-			iface.Methods.List = append(iface.Methods.List, elem.(*ast.Field))
+		if elem, ok := b.visit(elemCtx).(*ast.Field); ok {
+			iface.Methods.List = append(iface.Methods.List, elem)
 		}
 	}
 
@@ -334,11 +330,11 @@ func (b *ASTBuilder) VisitInterfaceElem(ctx *InterfaceElemContext) interface{} {
 	}
 
 	if methCtx := ctx.MethodElem(); methCtx != nil {
-		return b.VisitMethodElem(methCtx)
+		return b.visit(methCtx)
 	}
 
 	if typeCtx := ctx.TypeElem(); typeCtx != nil {
-		return b.VisitTypeElem(typeCtx)
+		return b.visit(typeCtx)
 	}
 
 	return nil
@@ -358,31 +354,55 @@ func (b *ASTBuilder) VisitMethodElem(ctx *MethodElemContext) interface{} {
 	}
 
 	// Method signature
-	if sigCtx := ctx.Signature(); sigCtx != nil {
-		if sig := b.VisitSignature(sigCtx); sig != nil {
-			field.Type = sig.(ast.Type)
-		}
-	}
+	field.Type = b.visitType(ctx.Signature())
 
 	return field
 }
 
-// VisitTypeElem transforms an interface type element (embedded type).
+// VisitTypeElem transforms an interface type element (embedded type or
+// type constraint union).
 func (b *ASTBuilder) VisitTypeElem(ctx *TypeElemContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
 
+	var terms []ast.Type
+	for _, termCtx := range ctx.AllTypeTerm() {
+		if term := b.visitType(termCtx); term != nil {
+			terms = append(terms, term)
+		}
+	}
+
 	field := &ast.Field{}
+	switch len(terms) {
+	case 0:
+	case 1:
+		field.Type = terms[0]
+	default:
+		field.Type = &ast.UnionType{Terms: terms}
+	}
+
+	return field
+}
+
+// VisitTypeTerm transforms a type term: T or ~T.
+func (b *ASTBuilder) VisitTypeTerm(ctx *TypeTermContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
 
-	// Embedded type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			field.Type = typ.(ast.Type)
+	typ := b.visitType(ctx.Type_())
+	if typ == nil {
+		return nil
+	}
+	if tilde := ctx.GetToken(tokenTilde, 0); tilde != nil {
+		return &ast.TildeType{
+			Tilde: b.terminalPos(tilde),
+			Type:  typ,
 		}
 	}
 
-	return field
+	return typ
 }
 
 // VisitMapType transforms a map type.
@@ -391,47 +411,71 @@ func (b *ASTBuilder) VisitMapType(ctx *MapTypeContext) interface{} {
 		return nil
 	}
 
-	mapType := &ast.MapType{
-		Map:    b.pos(ctx),
-		Lbrack: b.pos(ctx),
+	key, value := b.visitType(ctx.Type_()), b.visitType(ctx.ElementType())
+	if key == nil || value == nil {
+		return nil
 	}
+	return &ast.MapType{
+		Map:     b.terminalPos(ctx.MAP()),
+		Lbrack:  b.terminalPos(ctx.GetToken(tokenLBrack, 0)),
+		Pointer: ctx.GetToken(tokenStar, 0) != nil,
+		Key:     key,
+		Value:   value,
+	}
+}
 
-	// Key type
-	if keyCtx := ctx.Type_(0); keyCtx != nil {
-		if key := b.VisitType_(keyCtx); key != nil {
-			mapType.Key = key.(ast.Type)
-		}
+// VisitSendRecvChan transforms *chan T and *chan<- T.
+func (b *ASTBuilder) VisitSendRecvChan(ctx *SendRecvChanContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.chanType(ctx.GetToken(tokenArrow, 0), ctx.CHAN(), true, ast.ChanSend, ctx.ElementType())
+}
 
-	// Value type
-	if valCtx := ctx.Type_(1); valCtx != nil {
-		if val := b.VisitType_(valCtx); val != nil {
-			mapType.Value = val.(ast.Type)
-		}
+// VisitRecvOnlyChan transforms *<-chan T.
+func (b *ASTBuilder) VisitRecvOnlyChan(ctx *RecvOnlyChanContext) interface{} {
+	if ctx == nil {
+		return nil
 	}
+	return b.chanType(ctx.GetToken(tokenArrow, 0), ctx.CHAN(), true, ast.ChanRecv, ctx.ElementType())
+}
 
-	return mapType
+// VisitSendRecvChanCompat transforms chan T and chan<- T.
+func (b *ASTBuilder) VisitSendRecvChanCompat(ctx *SendRecvChanCompatContext) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return b.chanType(ctx.GetToken(tokenArrow, 0), ctx.CHAN(), false, ast.ChanSend, ctx.ElementType())
 }
 
-// VisitChannelType transforms a channel type.
-func (b *ASTBuilder) VisitChannelType(ctx *ChannelTypeContext) interface{} {
+// VisitRecvOnlyChanCompat transforms <-chan T.
+func (b *ASTBuilder) VisitRecvOnlyChanCompat(ctx *RecvOnlyChanCompatContext) interface{} {
 	if ctx == nil {
 		return nil
 	}
+	return b.chanType(ctx.GetToken(tokenArrow, 0), ctx.CHAN(), false, ast.ChanRecv, ctx.ElementType())
+}
 
+// chanType builds a channel type. The direction applies only if the
+// alternative contains an arrow; otherwise the channel is bidirectional.
+// It returns nil if the element type is missing after a syntax error.
+func (b *ASTBuilder) chanType(arrow, chanTok antlr.TerminalNode, pointer bool, dir ast.ChanDir, elem IElementTypeContext) ast.Type {
+	value := b.visitType(elem)
+	if value == nil {
+		return nil
+	}
 	chanType := &ast.ChanType{
-		Begin: b.pos(ctx),
-		Dir:   ast.ChanBoth,
+		Begin:   b.terminalPos(chanTok),
+		Dir:     ast.ChanBoth,
+		Pointer: pointer,
+		Value:   value,
 	}
 
-	// Determine channel direction
-	// This depends on the specific rule structure in your grammar
-	// For now, assume bidirectional
-
-	// Channel element type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			chanType.Value = typ.(ast.Type)
+	if arrow != nil {
+		chanType.Arrow = b.terminalPos(arrow)
+		chanType.Dir = dir
+		if dir == ast.ChanRecv {
+			chanType.Begin = chanType.Arrow
 		}
 	}
 
@@ -444,19 +488,11 @@ func (b *ASTBuilder) VisitFunctionType(ctx *FunctionTypeContext) interface{} {
 		return nil
 	}
 
-	funcType := &ast.FuncType{
-		Func: b.pos(ctx),
-	}
-
-	if sigCtx := ctx.Signature(); sigCtx != nil {
-		if sig := b.VisitSignature(sigCtx); sig != nil {
-			// Signature returns a FuncType
-			if ft, ok := sig.(*ast.FuncType); ok {
-				funcType.Params = ft.Params
-				funcType.Results = ft.Results
-			}
-		}
+	funcType, ok := b.visit(ctx.Signature()).(*ast.FuncType)
+	if !ok {
+		funcType = &ast.FuncType{}
 	}
+	funcType.Func = b.terminalPos(ctx.FUNC())
 
 	return funcType
 }
@@ -467,23 +503,10 @@ func (b *ASTBuilder) VisitSignature(ctx *SignatureContext) interface{} {
 		return nil
 	}
 
-	funcType := &ast.FuncType{}
-
-	// Parameters
-	if paramsCtx := ctx.Parameters(); paramsCtx != nil {
-		if params := b.VisitParameters(paramsCtx); params != nil {
-			funcType.Params = params.(*ast.FieldList)
-		}
-	}
-
-	// Results
-	if resultCtx := ctx.Result(); resultCtx != nil {
-		if result := b.VisitResult(resultCtx); result != nil {
-			funcType.Results = result.(*ast.FieldList)
-		}
+	return &ast.FuncType{
+		Params:  b.visitFieldList(ctx.Parameters()),
+		Results: b.visitFieldList(ctx.Result()),
 	}
-
-	return funcType
 }
 
 // VisitParameters transforms function parameters.
@@ -494,13 +517,13 @@ func (b *ASTBuilder) VisitParameters(ctx *ParametersContext) interface{} {
 
 	fieldList := &ast.FieldList{
 		Opening: b.pos(ctx),
-		Closing: b.endPos(ctx),
+		Closing: b.tokenPos(ctx.GetStop()),
 	}
 
 	// Add parameter declarations
 	for _, paramCtx := range ctx.AllParameterDecl() {
-		if param := b.VisitParameterDecl(paramCtx); param != nil {
-			fieldList.List = append(fieldList.List, param.(*ast.Field))
+		if param, ok := b.visit(paramCtx).(*ast.Field); ok {
+			fieldList.List = append(fieldList.List, param)
 		}
 	}
 
@@ -520,10 +543,12 @@ func (b *ASTBuilder) VisitParameterDecl(ctx *ParameterDeclContext) interface{} {
 		field.Names = b.visitIdentifierList(idListCtx)
 	}
 
-	// Parameter type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			field.Type = typ.(ast.Type)
+	// Parameter type, variadic if preceded by "..."
+	field.Type = b.visitType(ctx.Type_())
+	if ellipsis := ctx.GetToken(tokenEllipsis, 0); ellipsis != nil {
+		field.Type = &ast.Ellipsis{
+			Ellipsis: b.terminalPos(ellipsis),
+			Elt:      field.Type,
 		}
 	}
 
@@ -538,21 +563,17 @@ func (b *ASTBuilder) VisitResult(ctx *ResultContext) interface{} {
 
 	// If result has parameters (named or unnamed), visit them
 	if paramsCtx := ctx.Parameters(); paramsCtx != nil {
-		return b.VisitParameters(paramsCtx)
+		return b.visit(paramsCtx)
 	}
 
 	// If result is a single type
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		fieldList := &ast.FieldList{}
-		if typ := b.VisitType_(typeCtx); typ != nil {
-			fieldList.List = []*ast.Field{
-				{Type: typ.(ast.Type)},
-			}
+	fieldList := &ast.FieldList{}
+	if typ := b.visitType(ctx.Type_()); typ != nil {
+		fieldList.List = []*ast.Field{
+			{Type: typ},
 		}
-		return fieldList
 	}
-
-	return nil
+	return fieldList
 }
 
 // VisitConstType transforms a const type (Moxie feature).
@@ -561,13 +582,14 @@ func (b *ASTBuilder) VisitConstType(ctx *ConstTypeContext) interface{} {
 		return nil
 	}
 
-	// For now, treat const types as regular types
-	// We'll need to mark them as const in semantic analysis
-	if typeCtx := ctx.Type_(); typeCtx != nil {
-		return b.VisitType_(typeCtx)
+	typ := b.visitType(ctx.Type_())
+	if typ == nil {
+		return nil
+	}
+	return &ast.ConstType{
+		Const: b.pos(ctx),
+		Type:  typ,
 	}
-
-	return nil
 }
 
 // VisitQualifiedIdent transforms a qualified identifier (package.Name).
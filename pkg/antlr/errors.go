@@ -0,0 +1,78 @@
+package antlr
+
+import (
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// ErrorCollector is an ANTLR error listener that records lexer and parser
+// errors as diagnostics instead of printing them to the console.
+//
+// Install it on both the lexer and the parser after removing the default
+// console listeners:
+//
+//	collector := NewErrorCollector(filename)
+//	lexer.RemoveErrorListeners()
+//	lexer.AddErrorListener(collector)
+//	parser.RemoveErrorListeners()
+//	parser.AddErrorListener(collector)
+type ErrorCollector struct {
+	*antlr.DefaultErrorListener
+	filename  string
+	positions *PositionTable
+	diags     diag.List
+}
+
+// NewErrorCollector creates an error collector for the given filename.
+func NewErrorCollector(filename string) *ErrorCollector {
+	return &ErrorCollector{
+		DefaultErrorListener: antlr.NewDefaultErrorListener(),
+		filename:             filename,
+		positions:            NewPositionTable(filename),
+	}
+}
+
+// SyntaxError implements antlr.ErrorListener.
+func (c *ErrorCollector) SyntaxError(
+	recognizer antlr.Recognizer,
+	offendingSymbol interface{},
+	line, column int,
+	msg string,
+	e antlr.RecognitionException,
+) {
+	d := &diag.Diagnostic{
+		Pos: ast.Position{
+			Filename: c.filename,
+			Line:     line,
+			Column:   column + 1, // ANTLR columns are 0-based, AST are 1-based
+		},
+		Severity: diag.Error,
+		Code:     diag.SyntaxError,
+		Message:  msg,
+	}
+
+	// Parser errors carry the offending token, which gives the exact range.
+	if token, ok := offendingSymbol.(antlr.Token); ok && token.GetTokenType() != antlr.TokenEOF {
+		d.Pos = c.positions.Position(token)
+		d.End = c.positions.End(token)
+	}
+
+	c.diags.Add(d)
+}
+
+// Diagnostics returns the collected syntax errors.
+func (c *ErrorCollector) Diagnostics() diag.List {
+	return c.diags
+}
+
+// BuildError is an error found while converting a parse tree to an AST,
+// such as a go or defer statement without a function call.
+type BuildError struct {
+	Pos ast.Position
+	Msg string
+}
+
+func (e *BuildError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
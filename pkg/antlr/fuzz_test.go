@@ -0,0 +1,34 @@
+package antlr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addExamples adds the example programs to the seed corpus of f.
+func addExamples(f *testing.F) {
+	files, err := filepath.Glob("../../examples/*/*.x")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no examples found: %v", err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+}
+
+// FuzzParseMoxie checks that the scanner, parser and AST builder accept
+// any input without panicking, and build a file from whatever the parser
+// recovered.
+func FuzzParseMoxie(f *testing.F) {
+	addExamples(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		if file, _ := ParseFile("fuzz.mx", src); file == nil {
+			t.Fatal("no file")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package antlr
+
+import (
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// ParseFile parses the Moxie source src and builds its AST, including its
+// comments. Syntax errors and AST building errors are returned as
+// diagnostics; the file is built even when there are errors, from whatever
+// the parser recovered.
+func ParseFile(filename, src string) (*ast.File, diag.List) {
+	collector := NewErrorCollector(filename)
+
+	lexer := NewScanner(antlr.NewInputStream(src))
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(collector)
+
+	parser := NewMoxieParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(collector)
+	parser.AddErrorListener(lexer.ErrorListener())
+
+	tree := parser.SourceFile()
+	builder := NewASTBuilder(filename)
+	builder.SetComments(lexer.Comments())
+	file, _ := builder.visit(tree).(*ast.File)
+	errs := builder.Errors()
+
+	diags := collector.Diagnostics()
+	for _, err := range errs {
+		pos, msg := ast.Position{Filename: filename}, err.Error()
+		if buildErr, ok := err.(*BuildError); ok {
+			pos, msg = buildErr.Pos, buildErr.Msg
+		}
+		diags.Add(diag.Errorf(pos, diag.BuildError, "%s", msg))
+	}
+	diags.Sort()
+
+	return file, diags
+}
@@ -6,6 +6,10 @@ import (
 )
 
 // TokenToPosition converts an ANTLR token to an AST position.
+//
+// ANTLR counts offsets and columns in runes. For source containing
+// non-ASCII characters use a PositionTable, which reports byte offsets and
+// columns as documented for ast.Position.
 func TokenToPosition(token antlr.Token, filename string) ast.Position {
 	if token == nil {
 		return ast.Position{}
@@ -27,7 +31,8 @@ func ContextToPosition(ctx antlr.ParserRuleContext, filename string) ast.Positio
 	return TokenToPosition(token, filename)
 }
 
-// ContextEndPosition returns the ending position of a parser context.
+// ContextEndPosition returns the position immediately after the last token
+// of a parser context.
 func ContextEndPosition(ctx antlr.ParserRuleContext, filename string) ast.Position {
 	if ctx == nil {
 		return ast.Position{}
@@ -36,11 +41,78 @@ func ContextEndPosition(ctx antlr.ParserRuleContext, filename string) ast.Positi
 	if token == nil {
 		return ContextToPosition(ctx, filename)
 	}
+	return tokenEnd(TokenToPosition(token, filename), token)
+}
+
+// tokenEnd returns the position immediately after token, which starts at
+// pos. EOF has no text and ends where it starts.
+func tokenEnd(pos ast.Position, token antlr.Token) ast.Position {
+	if token.GetTokenType() == antlr.TokenEOF {
+		return pos
+	}
+	return pos.Advance(token.GetText())
+}
 
-	pos := TokenToPosition(token, filename)
-	// Add the token length to get the true end position
-	text := token.GetText()
-	pos.Column += len(text)
-	pos.Offset += len(text)
+// PositionTable converts token positions to AST positions with byte
+// offsets and columns. It is built from the token's input stream on first
+// use.
+type PositionTable struct {
+	filename string
+	input    antlr.CharStream
+	offsets  []int // Byte offset of each rune index and of the end; nil for ASCII input
+}
+
+// NewPositionTable creates a position table for the given filename.
+func NewPositionTable(filename string) *PositionTable {
+	return &PositionTable{filename: filename}
+}
+
+// Position returns the position of the start of token.
+func (t *PositionTable) Position(token antlr.Token) ast.Position {
+	pos := TokenToPosition(token, t.filename)
+	if token == nil {
+		return pos
+	}
+
+	t.load(token.GetInputStream())
+	start := token.GetStart()
+	lineStart := start - token.GetColumn()
+	if t.offsets == nil || start < 0 || start >= len(t.offsets) || lineStart < 0 {
+		return pos
+	}
+	pos.Offset = t.offsets[start]
+	pos.Column = t.offsets[start] - t.offsets[lineStart] + 1
 	return pos
 }
+
+// End returns the position immediately after token.
+func (t *PositionTable) End(token antlr.Token) ast.Position {
+	if token == nil {
+		return ast.Position{}
+	}
+	return tokenEnd(t.Position(token), token)
+}
+
+// load builds the offset table for input, unless it already has.
+func (t *PositionTable) load(input antlr.CharStream) {
+	if input == nil || input == t.input {
+		return
+	}
+	t.input = input
+	t.offsets = nil
+
+	size := input.Size()
+	if size == 0 {
+		return
+	}
+	text := input.GetText(0, size-1)
+	if len(text) == size {
+		return // ASCII: rune indexes are byte offsets
+	}
+
+	t.offsets = make([]int, 0, size+1)
+	for i := range text {
+		t.offsets = append(t.offsets, i)
+	}
+	t.offsets = append(t.offsets, len(text))
+}
@@ -0,0 +1,495 @@
+package antlr
+
+import (
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+// Token types of the generated lexer used by the scanner and the AST
+// builder. The literal tokens ('(', ';', ...) have no symbolic names in the
+// grammar.
+const (
+	tokenLParen    = MoxieLexerT__0
+	tokenRParen    = MoxieLexerT__1
+	tokenPeriod    = MoxieLexerT__2
+	tokenAssign    = MoxieLexerT__3
+	tokenLBrack    = MoxieLexerT__4
+	tokenComma     = MoxieLexerT__5
+	tokenRBrack    = MoxieLexerT__6
+	tokenStar      = MoxieLexerT__7
+	tokenLBrace    = MoxieLexerT__8
+	tokenRBrace    = MoxieLexerT__9
+	tokenEllipsis  = MoxieLexerT__10
+	tokenOr        = MoxieLexerT__11
+	tokenTilde     = MoxieLexerT__12
+	tokenArrow     = MoxieLexerT__13
+	tokenInc       = MoxieLexerT__14
+	tokenDec       = MoxieLexerT__15
+	tokenAmp       = MoxieLexerT__23
+	tokenDefine    = MoxieLexerT__25
+	tokenColon     = MoxieLexerT__26
+	tokenSemicolon = MoxieLexerT__27
+	tokenLAnd      = MoxieLexerT__28
+	tokenLOr       = MoxieLexerT__29
+	tokenNative    = MoxieLexerT__30
+	tokenLittle    = MoxieLexerT__31
+	tokenBig       = MoxieLexerT__32
+)
+
+// frameKind classifies an open bracket.
+type frameKind int
+
+const (
+	frameRoot      frameKind = iota // Top level of the file
+	frameBlock                      // Statement block
+	frameFields                     // Struct or interface body
+	frameDeclGroup                  // Parenthesized import/const/var/type group
+	frameOther                      // Parentheses, brackets, composite literals
+)
+
+// frame is an open bracket on the scanner's nesting stack.
+type frame struct {
+	kind   frameKind
+	open   int  // Token type of the opening bracket; 0 for the root
+	header bool // A '{' at this level opens a block (after if, for, func, ...)
+}
+
+// Scanner wraps the generated MoxieLexer and adapts its token stream to
+// the parser rules:
+//
+//   - Semicolons are inserted automatically at line ends following the Go
+//     rules, inside blocks, struct and interface bodies and declaration
+//     groups, after the package clause, and before a closing '}' or ')'
+//     of such a list. The grammar requires them there but the lexer
+//     discards newlines. Inserted semicolons have the text "\n".
+//   - Predeclared type names (int, string, ...) and constants (nil, true,
+//     false, iota) are reported as IDENTIFIER, since the grammar refers to
+//     them through type and operand names.
+//   - Decimal, binary, octal and hexadecimal integers are reported as
+//     INT_LIT.
+//   - Comments, which the lexer skips, are recovered from the text between
+//     tokens and collected separately; see Comments.
+//   - Brackets left open by a syntax error are closed by an enclosing
+//     closing bracket or before the next top-level declaration, so the
+//     error does not affect the rest of the file.
+//
+// Use it in place of NewMoxieLexer when parsing source code, and install
+// its ErrorListener on the parser:
+//
+//	lexer := NewScanner(antlr.NewInputStream(src))
+//	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+//	parser := NewMoxieParser(stream)
+//	parser.AddErrorListener(lexer.ErrorListener())
+type Scanner struct {
+	*MoxieLexer
+
+	stack    []frame
+	last     antlr.Token // Last token returned
+	lastType int         // Token type of last
+	lastLine int         // Line on which last ends
+	pending  antlr.Token // Token read ahead while inserting a semicolon
+	ahead    antlr.Token // Lexer token read ahead, not yet returned
+	held     antlr.Token // Token held back while closing unclosed brackets
+	pkgLine  bool        // Inside the package clause
+	declAt   int         // Input index of the top-level bracket still open
+	errorAt  int         // Input index of the last parser error; -1 if none
+
+	comments []antlr.Token // Comments read so far
+	gapStart int           // Input index just after the last lexer token
+	gapLine  int           // Line of gapStart
+	gapCol   int           // Column of gapStart
+}
+
+// NewScanner creates a scanner reading from input.
+func NewScanner(input antlr.CharStream) *Scanner {
+	return &Scanner{
+		MoxieLexer: NewMoxieLexer(input),
+		stack:      []frame{{kind: frameRoot}},
+		gapLine:    1,
+		errorAt:    -1,
+	}
+}
+
+// ErrorListener returns a listener to install on the parser, which tells
+// the scanner where syntax errors are. Without it, the scanner only closes
+// the brackets still open before a declaration that cannot be nested in
+// them.
+func (s *Scanner) ErrorListener() antlr.ErrorListener {
+	return errorListener{antlr.NewDefaultErrorListener(), s}
+}
+
+// errorListener records the position of parser errors in its scanner.
+type errorListener struct {
+	*antlr.DefaultErrorListener
+	s *Scanner
+}
+
+// SyntaxError implements antlr.ErrorListener.
+func (l errorListener) SyntaxError(_ antlr.Recognizer, offendingSymbol interface{}, _, _ int, _ string, _ antlr.RecognitionException) {
+	if tok, ok := offendingSymbol.(antlr.Token); ok && tok.GetStart() > l.s.errorAt {
+		l.s.errorAt = tok.GetStart()
+	}
+}
+
+// Comments returns the comments read so far, in source order, as tokens
+// of type MoxieLexerLINE_COMMENT or MoxieLexerBLOCK_COMMENT. After the
+// parser has consumed the input these are all comments in the file.
+func (s *Scanner) Comments() []antlr.Token {
+	return s.comments
+}
+
+// seek moves the scanner to index of its input, which is at the given line
+// and column, so that a part of the source is scanned with the token
+// positions of the whole file.
+func (s *Scanner) seek(index, line, col int) {
+	s.GetInputStream().Seek(index)
+	if sim, ok := s.Interpreter.(*antlr.LexerATNSimulator); ok {
+		sim.Line, sim.CharPositionInLine = line, col
+	}
+	s.gapStart, s.gapLine, s.gapCol = index, line, col
+}
+
+// NextToken returns the next token for the parser.
+func (s *Scanner) NextToken() antlr.Token {
+	var tok antlr.Token
+	switch {
+	case s.pending != nil:
+		tok, s.pending = s.pending, nil
+	case s.held != nil && len(s.stack) > 1:
+		tok = s.closer(s.held)
+	case s.held != nil:
+		tok, s.held = s.held, nil
+	default:
+		tok = s.lex()
+		if s.unclosed(tok) {
+			s.held = tok
+			tok = s.closer(tok)
+		}
+	}
+
+	if s.needSemicolon(tok) {
+		s.pending = tok
+		tok = s.semicolon()
+	}
+
+	s.track(tok)
+	return tok
+}
+
+// lex returns the next lexer token, normalized.
+func (s *Scanner) lex() antlr.Token {
+	if tok := s.ahead; tok != nil {
+		s.ahead = nil
+		return tok
+	}
+	tok := s.MoxieLexer.NextToken()
+	s.scanComments(tok)
+	return s.normalize(tok)
+}
+
+// closers maps opening brackets to the closing bracket token type and text.
+var closers = map[int]struct {
+	ttype int
+	text  string
+}{
+	tokenLParen: {tokenRParen, ")"},
+	tokenLBrack: {tokenRBrack, "]"},
+	tokenLBrace: {tokenRBrace, "}"},
+}
+
+// unclosed reports whether tok starts a top-level declaration while
+// brackets are still open, which happens after a missing '}' or ')', and
+// if so reports the missing brackets as a syntax error. The scanner then
+// closes the brackets before tok, so the error does not spread to the rest
+// of the file.
+//
+// A declaration that cannot be nested in the open brackets is always taken
+// as top-level: import, func followed by a name, and var and type outside
+// a statement block. Otherwise, var, type and const, which also starts a
+// const type, and func followed by '(' are only taken as top-level after
+// the parser has reported an error since the outermost bracket was opened.
+func (s *Scanner) unclosed(tok antlr.Token) bool {
+	if len(s.stack) == 1 {
+		return false
+	}
+	kind := tok.GetText()
+	inBlock := s.stack[len(s.stack)-1].kind == frameBlock
+	switch tok.GetTokenType() {
+	case MoxieLexerIMPORT:
+	case MoxieLexerFUNC:
+		s.ahead = s.lex()
+		switch s.ahead.GetTokenType() {
+		case MoxieLexerIDENTIFIER:
+		case tokenLParen:
+			if !s.failed() {
+				return false
+			}
+		default:
+			return false
+		}
+		kind = "function"
+	case MoxieLexerTYPE:
+		if s.lastType == tokenLParen || inBlock && !s.failed() { // x.(type) in a type switch
+			return false
+		}
+	case MoxieLexerVAR:
+		if inBlock && !s.failed() {
+			return false
+		}
+	case MoxieLexerCONST:
+		if !s.failed() {
+			return false
+		}
+	default:
+		return false
+	}
+
+	var missing strings.Builder
+	for i := len(s.stack) - 1; i > 0; i-- {
+		missing.WriteString(closers[s.stack[i].open].text)
+	}
+	s.GetErrorListenerDispatch().SyntaxError(s, tok, tok.GetLine(), tok.GetColumn(),
+		"missing '"+missing.String()+"' before "+kind+" declaration", nil)
+	return true
+}
+
+// failed reports whether the parser has reported an error since the
+// outermost open bracket.
+func (s *Scanner) failed() bool {
+	return s.errorAt >= s.declAt
+}
+
+// closer creates a closing bracket for the innermost open bracket, empty
+// and at the position of tok.
+func (s *Scanner) closer(tok antlr.Token) antlr.Token {
+	c := closers[s.stack[len(s.stack)-1].open]
+	return s.GetTokenFactory().Create(tok.GetSource(), c.ttype, c.text, antlr.TokenDefaultChannel,
+		tok.GetStart(), tok.GetStart()-1, tok.GetLine(), tok.GetColumn())
+}
+
+// normalize maps predeclared names and integer literals to the token types
+// expected by the parser.
+func (s *Scanner) normalize(tok antlr.Token) antlr.Token {
+	ttype := tok.GetTokenType()
+	switch {
+	case MoxieLexerBOOL <= ttype && ttype <= MoxieLexerIOTA:
+		ttype = MoxieLexerIDENTIFIER
+	case MoxieLexerDECIMAL_LIT <= ttype && ttype <= MoxieLexerHEX_LIT:
+		ttype = MoxieLexerINT_LIT
+	default:
+		return tok
+	}
+	return s.GetTokenFactory().Create(tok.GetSource(), ttype, tok.GetText(), tok.GetChannel(),
+		tok.GetStart(), tok.GetStop(), tok.GetLine(), tok.GetColumn())
+}
+
+// scanComments collects the comments in the input between the previous
+// lexer token and tok. The lexer skips comments along with white space, so
+// the text in between contains nothing else.
+func (s *Scanner) scanComments(tok antlr.Token) {
+	start, end := s.gapStart, tok.GetStart()
+	line, col := s.gapLine, s.gapCol
+	defer func() {
+		s.gapStart = tok.GetStop() + 1
+		s.gapLine, s.gapCol = advance(tok.GetLine(), tok.GetColumn(), []rune(tok.GetText()))
+	}()
+	if size := s.GetInputStream().Size(); end > size {
+		end = size
+	}
+	if end <= start {
+		return
+	}
+
+	gap := []rune(s.GetInputStream().GetText(start, end-1))
+	for i := 0; i < len(gap); {
+		if gap[i] != '/' || i+1 >= len(gap) || (gap[i+1] != '/' && gap[i+1] != '*') {
+			line, col = advance(line, col, gap[i:i+1])
+			i++
+			continue
+		}
+
+		ttype, j := MoxieLexerLINE_COMMENT, i+2
+		if gap[i+1] == '/' {
+			for j < len(gap) && gap[j] != '\n' {
+				j++
+			}
+			if j > i+2 && gap[j-1] == '\r' {
+				j--
+			}
+		} else {
+			ttype = MoxieLexerBLOCK_COMMENT
+			for j < len(gap) && !(gap[j-1] == '*' && gap[j] == '/' && j > i+2) {
+				j++
+			}
+			if j < len(gap) {
+				j++
+			}
+		}
+
+		text := gap[i:j]
+		s.comments = append(s.comments, s.GetTokenFactory().Create(tok.GetSource(), ttype, string(text),
+			antlr.TokenHiddenChannel, start+i, start+j-1, line, col))
+		line, col = advance(line, col, text)
+		i = j
+	}
+}
+
+// advance returns the line and column following text, which starts at the
+// given line and column.
+func advance(line, col int, text []rune) (int, int) {
+	for _, r := range text {
+		if r == '\n' {
+			line, col = line+1, 0
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// needSemicolon reports whether a semicolon must be inserted before tok.
+func (s *Scanner) needSemicolon(tok antlr.Token) bool {
+	if s.last == nil {
+		return false
+	}
+	top := s.stack[len(s.stack)-1]
+	ttype := tok.GetTokenType()
+
+	// Close of a statement, field or spec list on the same line.
+	switch {
+	case ttype == tokenRBrace && (top.kind == frameBlock || top.kind == frameFields),
+		ttype == tokenRParen && top.kind == frameDeclGroup:
+		switch s.lastType {
+		case tokenSemicolon, tokenLBrace, tokenLParen, tokenColon:
+			return false
+		}
+		return true
+	}
+
+	// Line end.
+	if ttype != antlr.TokenEOF && tok.GetLine() <= s.lastLine {
+		return false
+	}
+	switch top.kind {
+	case frameBlock, frameFields, frameDeclGroup:
+	case frameRoot:
+		if !s.pkgLine || ttype == antlr.TokenEOF {
+			return false
+		}
+	default:
+		return false
+	}
+	return endsStatement(s.lastType)
+}
+
+// endsStatement reports whether a token of type ttype at the end of a line
+// terminates a statement.
+func endsStatement(ttype int) bool {
+	switch ttype {
+	case MoxieLexerIDENTIFIER,
+		MoxieLexerINT_LIT, MoxieLexerFLOAT_LIT, MoxieLexerIMAGINARY_LIT, MoxieLexerRUNE_LIT,
+		MoxieLexerRAW_STRING_LIT, MoxieLexerINTERPRETED_STRING_LIT,
+		MoxieLexerBREAK, MoxieLexerCONTINUE, MoxieLexerFALLTHROUGH, MoxieLexerRETURN,
+		tokenInc, tokenDec, tokenRParen, tokenRBrack, tokenRBrace,
+		tokenNative, tokenLittle, tokenBig:
+		return true
+	}
+	return false
+}
+
+// semicolon creates an automatically inserted semicolon following the last
+// token.
+func (s *Scanner) semicolon() antlr.Token {
+	end := s.last.GetStop() + 1
+	text := s.last.GetText()
+	line := s.last.GetLine()
+	column := s.last.GetColumn()
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		line += strings.Count(text, "\n")
+		column = len(text) - i - 1
+	} else {
+		column += len(text)
+	}
+	return s.GetTokenFactory().Create(s.last.GetSource(), tokenSemicolon, "\n", antlr.TokenDefaultChannel,
+		end, end-1, line, column)
+}
+
+// close pops the frame of the innermost open bracket of type open, and the
+// frames of unclosed brackets inside it, so that a syntax error such as a
+// missing ')' does not leave the rest of the file without semicolons. A
+// closing bracket without an open one is ignored.
+func (s *Scanner) close(open int) {
+	for i := len(s.stack) - 1; i > 0; i-- {
+		if s.stack[i].open == open {
+			s.stack = s.stack[:i]
+			return
+		}
+	}
+}
+
+// track updates the nesting state after tok has been returned.
+func (s *Scanner) track(tok antlr.Token) {
+	ttype := tok.GetTokenType()
+	top := &s.stack[len(s.stack)-1]
+	if len(s.stack) == 1 {
+		s.declAt = tok.GetStart()
+	}
+
+	switch ttype {
+	case MoxieLexerPACKAGE:
+		s.pkgLine = len(s.stack) == 1
+	case tokenSemicolon:
+		s.pkgLine = false
+		if tok.GetText() == "\n" {
+			top.header = false
+		}
+	case MoxieLexerIF, MoxieLexerFOR, MoxieLexerSWITCH, MoxieLexerSELECT, MoxieLexerELSE:
+		top.header = true
+	case MoxieLexerFUNC:
+		// In []func(){...} and map[K]func(){...} the brace opens a
+		// composite literal rather than a function body.
+		top.header = s.lastType != tokenRBrack
+	case MoxieLexerIMPORT, MoxieLexerCONST, MoxieLexerVAR:
+		top.header = false
+	case MoxieLexerTYPE:
+		if s.lastType != tokenLParen { // x.(type) in a type switch
+			top.header = false
+		}
+	case tokenLBrace:
+		kind := frameOther
+		switch {
+		case s.lastType == MoxieLexerSTRUCT || s.lastType == MoxieLexerINTERFACE:
+			kind = frameFields
+		case top.header:
+			kind = frameBlock
+			top.header = false
+		case top.kind == frameBlock &&
+			(s.lastType == tokenSemicolon || s.lastType == tokenLBrace || s.lastType == tokenColon):
+			kind = frameBlock // Nested block statement
+		}
+		s.stack = append(s.stack, frame{kind: kind, open: tokenLBrace})
+	case tokenLParen:
+		kind := frameOther
+		switch s.lastType {
+		case MoxieLexerIMPORT, MoxieLexerCONST, MoxieLexerVAR, MoxieLexerTYPE:
+			kind = frameDeclGroup
+		}
+		s.stack = append(s.stack, frame{kind: kind, open: tokenLParen})
+	case tokenLBrack:
+		s.stack = append(s.stack, frame{kind: frameOther, open: tokenLBrack})
+	case tokenRParen:
+		s.close(tokenLParen)
+	case tokenRBrack:
+		s.close(tokenLBrack)
+	case tokenRBrace:
+		s.close(tokenLBrace)
+	}
+
+	s.last = tok
+	s.lastType = ttype
+	s.lastLine = tok.GetLine()
+	if ttype == MoxieLexerRAW_STRING_LIT {
+		s.lastLine += strings.Count(tok.GetText(), "\n")
+	}
+}
@@ -4,7 +4,7 @@ package ast
 // Node is the base interface for all AST nodes.
 type Node interface {
 	Pos() Position // Starting position of the node
-	End() Position // Ending position of the node
+	End() Position // Position immediately after the node
 	node()         // Marker method to ensure only AST nodes implement this interface
 }
 
@@ -45,6 +45,21 @@ func (p Position) IsValid() bool {
 	return p.Line > 0
 }
 
+// Advance returns the position immediately after text, assuming text
+// starts at p. Newlines in text advance the line and reset the column.
+func (p Position) Advance(text string) Position {
+	p.Offset += len(text)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
 // String returns a string representation of the position.
 func (p Position) String() string {
 	if !p.IsValid() {
@@ -0,0 +1,87 @@
+package ast
+
+import "sort"
+
+// ============================================================================
+// Comment Map
+// ============================================================================
+
+// CommentMap maps an AST node to the comment groups associated with it.
+// Declarations, specs, struct and interface fields carry their doc and
+// line comments directly (Doc and Comment fields); a CommentMap also
+// covers statements, which have no comment fields.
+type CommentMap map[Node][]*CommentGroup
+
+// NewCommentMap associates each comment group in comments with a
+// declaration, spec, statement or field of the tree rooted at node,
+// following the rules of go/ast.NewCommentMap. A comment group g is
+// associated with node n if:
+//
+//   - g starts on the same line as n ends, or
+//   - g starts on the line after n ends, and there is at least one empty
+//     line after g and before the next node, or
+//   - g starts before n and is not associated with the node before n by
+//     the previous rules.
+//
+// Among nodes starting or ending at the same place the outermost one is
+// used. Comment groups with no such node are associated with node itself.
+func NewCommentMap(node Node, comments []*CommentGroup) CommentMap {
+	cmap := make(CommentMap)
+	if len(comments) == 0 {
+		return cmap
+	}
+
+	var nodes []Node
+	Inspect(node, func(n Node) bool {
+		switch n.(type) {
+		case Decl, Spec, Stmt, *Field:
+			if n.Pos().IsValid() {
+				nodes = append(nodes, n)
+			}
+		}
+		return true
+	})
+
+	for _, g := range comments {
+		start, end := g.Pos(), g.End()
+
+		// The node ending last before g, and the node starting first after
+		// it; nodes is in pre-order, so the first match is the outermost.
+		var prev, next Node
+		for _, n := range nodes {
+			if e := n.End(); e.Offset <= start.Offset && (prev == nil || e.Offset > prev.End().Offset) {
+				prev = n
+			}
+			if p := n.Pos(); p.Offset >= end.Offset && (next == nil || p.Offset < next.Pos().Offset) {
+				next = n
+			}
+		}
+
+		var target Node
+		switch {
+		case prev != nil && prev.End().Line == start.Line:
+			target = prev
+		case prev != nil && prev.End().Line+1 == start.Line &&
+			(next == nil || next.Pos().Line > end.Line+1):
+			target = prev
+		case next != nil:
+			target = next
+		default:
+			target = node
+		}
+		cmap[target] = append(cmap[target], g)
+	}
+	return cmap
+}
+
+// Comments returns all comment groups in the map, in source order.
+func (cmap CommentMap) Comments() []*CommentGroup {
+	var list []*CommentGroup
+	for _, groups := range cmap {
+		list = append(list, groups...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pos().Offset < list[j].Pos().Offset
+	})
+	return list
+}
@@ -6,12 +6,13 @@ package ast
 
 // File represents a Moxie source file.
 type File struct {
-	Package    *PackageClause  // Package clause
-	Imports    []*ImportDecl   // Import declarations
-	Decls      []Decl          // Top-level declarations (const, var, type, func)
-	Comments   []*CommentGroup // Comments in the file
-	StartPos   Position        // Start of file
-	EndPos     Position        // End of file
+	Doc      *CommentGroup   // Comment above the package clause, may be nil
+	Package  *PackageClause  // Package clause
+	Imports  []*ImportDecl   // Import declarations
+	Decls    []Decl          // Top-level declarations (const, var, type, func)
+	Comments []*CommentGroup // Comments in the file
+	StartPos Position        // Start of file
+	EndPos   Position        // End of file
 }
 
 func (f *File) Pos() Position { return f.StartPos }
@@ -35,7 +36,7 @@ type Comment struct {
 }
 
 func (c *Comment) Pos() Position { return c.Slash }
-func (c *Comment) End() Position { return Position{Line: c.Slash.Line, Column: c.Slash.Column + len(c.Text)} }
+func (c *Comment) End() Position { return c.Slash.Advance(c.Text) }
 func (c *Comment) node()         {}
 
 // CommentGroup represents a sequence of comments with no blank lines between them.
@@ -75,16 +76,17 @@ func (g *CommentGroup) Text() string {
 
 // ImportDecl represents an import declaration.
 type ImportDecl struct {
-	Import Position     // Position of "import" keyword
-	Lparen Position     // Position of "(" (invalid if not grouped)
+	Doc    *CommentGroup // Associated documentation, may be nil
+	Import Position      // Position of "import" keyword
+	Lparen Position      // Position of "(" (invalid if not grouped)
 	Specs  []*ImportSpec // Import specs
-	Rparen Position     // Position of ")" (invalid if not grouped)
+	Rparen Position      // Position of ")" (invalid if not grouped)
 }
 
 func (d *ImportDecl) Pos() Position { return d.Import }
 func (d *ImportDecl) End() Position {
 	if d.Rparen.IsValid() {
-		return d.Rparen
+		return d.Rparen.Advance(")")
 	}
 	if len(d.Specs) > 0 {
 		return d.Specs[len(d.Specs)-1].End()
@@ -96,8 +98,10 @@ func (d *ImportDecl) decl() {}
 
 // ImportSpec represents a single import specification.
 type ImportSpec struct {
-	Name   *Ident    // Local name (may be nil for default import, "." for dot import, "_" for side-effect)
-	Path   *BasicLit // Import path (string literal)
+	Doc     *CommentGroup // Associated documentation, may be nil
+	Name    *Ident        // Local name (may be nil for default import, "." for dot import, "_" for side-effect)
+	Path    *BasicLit     // Import path (string literal)
+	Comment *CommentGroup // Line comment, may be nil
 }
 
 func (s *ImportSpec) Pos() Position {
@@ -116,16 +120,17 @@ func (s *ImportSpec) spec()         {}
 
 // ConstDecl represents a const declaration.
 type ConstDecl struct {
-	Const  Position    // Position of "const" keyword
-	Lparen Position    // Position of "(" (invalid if not grouped)
-	Specs  []*ConstSpec // Const specs
-	Rparen Position    // Position of ")" (invalid if not grouped)
+	Doc    *CommentGroup // Associated documentation, may be nil
+	Const  Position      // Position of "const" keyword
+	Lparen Position      // Position of "(" (invalid if not grouped)
+	Specs  []*ConstSpec  // Const specs
+	Rparen Position      // Position of ")" (invalid if not grouped)
 }
 
 func (d *ConstDecl) Pos() Position { return d.Const }
 func (d *ConstDecl) End() Position {
 	if d.Rparen.IsValid() {
-		return d.Rparen
+		return d.Rparen.Advance(")")
 	}
 	if len(d.Specs) > 0 {
 		return d.Specs[len(d.Specs)-1].End()
@@ -137,9 +142,11 @@ func (d *ConstDecl) decl() {}
 
 // ConstSpec represents a const specification.
 type ConstSpec struct {
-	Names  []*Ident  // Constant names
-	Type   Type      // Type (may be nil)
-	Values []Expr    // Values (initializers)
+	Doc     *CommentGroup // Associated documentation, may be nil
+	Names   []*Ident      // Constant names
+	Type    Type          // Type (may be nil)
+	Values  []Expr        // Values (initializers)
+	Comment *CommentGroup // Line comment, may be nil
 }
 
 func (s *ConstSpec) Pos() Position {
@@ -169,16 +176,17 @@ func (s *ConstSpec) spec() {}
 
 // VarDecl represents a var declaration.
 type VarDecl struct {
-	Var    Position   // Position of "var" keyword
-	Lparen Position   // Position of "(" (invalid if not grouped)
-	Specs  []*VarSpec // Var specs
-	Rparen Position   // Position of ")" (invalid if not grouped)
+	Doc    *CommentGroup // Associated documentation, may be nil
+	Var    Position      // Position of "var" keyword
+	Lparen Position      // Position of "(" (invalid if not grouped)
+	Specs  []*VarSpec    // Var specs
+	Rparen Position      // Position of ")" (invalid if not grouped)
 }
 
 func (d *VarDecl) Pos() Position { return d.Var }
 func (d *VarDecl) End() Position {
 	if d.Rparen.IsValid() {
-		return d.Rparen
+		return d.Rparen.Advance(")")
 	}
 	if len(d.Specs) > 0 {
 		return d.Specs[len(d.Specs)-1].End()
@@ -190,9 +198,11 @@ func (d *VarDecl) decl() {}
 
 // VarSpec represents a var specification.
 type VarSpec struct {
-	Names  []*Ident  // Variable names
-	Type   Type      // Type (may be nil if values are present)
-	Values []Expr    // Values (initializers, may be nil)
+	Doc     *CommentGroup // Associated documentation, may be nil
+	Names   []*Ident      // Variable names
+	Type    Type          // Type (may be nil if values are present)
+	Values  []Expr        // Values (initializers, may be nil)
+	Comment *CommentGroup // Line comment, may be nil
 }
 
 func (s *VarSpec) Pos() Position {
@@ -222,16 +232,17 @@ func (s *VarSpec) spec() {}
 
 // TypeDecl represents a type declaration.
 type TypeDecl struct {
-	Type   Position    // Position of "type" keyword
-	Lparen Position    // Position of "(" (invalid if not grouped)
-	Specs  []*TypeSpec // Type specs
-	Rparen Position    // Position of ")" (invalid if not grouped)
+	Doc    *CommentGroup // Associated documentation, may be nil
+	Type   Position      // Position of "type" keyword
+	Lparen Position      // Position of "(" (invalid if not grouped)
+	Specs  []*TypeSpec   // Type specs
+	Rparen Position      // Position of ")" (invalid if not grouped)
 }
 
 func (d *TypeDecl) Pos() Position { return d.Type }
 func (d *TypeDecl) End() Position {
 	if d.Rparen.IsValid() {
-		return d.Rparen
+		return d.Rparen.Advance(")")
 	}
 	if len(d.Specs) > 0 {
 		return d.Specs[len(d.Specs)-1].End()
@@ -243,16 +254,26 @@ func (d *TypeDecl) decl() {}
 
 // TypeSpec represents a type specification (type definition or alias).
 type TypeSpec struct {
-	Name       *Ident     // Type name
-	TypeParams *FieldList // Type parameters (generics), may be nil
-	Assign     Position   // Position of "=" (invalid if not an alias)
-	Type       Type       // Underlying type
+	Doc        *CommentGroup // Associated documentation, may be nil
+	Name       *Ident        // Type name
+	TypeParams *FieldList    // Type parameters (generics), may be nil
+	Assign     Position      // Position of "=" (invalid if not an alias)
+	Type       Type          // Underlying type
+	Comment    *CommentGroup // Line comment, may be nil
 }
 
 func (s *TypeSpec) Pos() Position { return s.Name.Pos() }
-func (s *TypeSpec) End() Position { return s.Type.End() }
-func (s *TypeSpec) node()         {}
-func (s *TypeSpec) spec()         {}
+func (s *TypeSpec) End() Position {
+	if s.Type != nil {
+		return s.Type.End()
+	}
+	if s.TypeParams != nil {
+		return s.TypeParams.End()
+	}
+	return s.Name.End()
+}
+func (s *TypeSpec) node() {}
+func (s *TypeSpec) spec() {}
 
 // IsAlias returns true if this is a type alias (type A = B).
 func (s *TypeSpec) IsAlias() bool {
@@ -265,13 +286,17 @@ func (s *TypeSpec) IsAlias() bool {
 
 // FuncDecl represents a function declaration.
 type FuncDecl struct {
-	Recv *FieldList // Receiver (for methods), may be nil
-	Name *Ident     // Function name
-	Type *FuncType  // Function signature
-	Body *BlockStmt // Function body (may be nil for external functions)
+	Doc  *CommentGroup // Associated documentation, may be nil
+	Recv *FieldList    // Receiver (for methods), may be nil
+	Name *Ident        // Function name
+	Type *FuncType     // Function signature
+	Body *BlockStmt    // Function body (may be nil for external functions)
 }
 
 func (d *FuncDecl) Pos() Position {
+	if d.Type != nil && d.Type.Func.IsValid() {
+		return d.Type.Func
+	}
 	if d.Recv != nil {
 		return d.Recv.Pos()
 	}
@@ -129,3 +129,31 @@ func Example_tokens() {
 	// CLONE token: clone
 	// Is keyword: true
 }
+
+// Example demonstrates traversing an AST with Inspect.
+func Example_inspect() {
+	// Build AST for: x = a + b
+	stmt := &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.Ident{Name: "x"}},
+		Tok: ast.ASSIGN,
+		Rhs: []ast.Expr{
+			&ast.BinaryExpr{
+				X:  &ast.Ident{Name: "a"},
+				Op: ast.ADD,
+				Y:  &ast.Ident{Name: "b"},
+			},
+		},
+	}
+
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			fmt.Println(ident.Name)
+		}
+		return true
+	})
+
+	// Output:
+	// x
+	// a
+	// b
+}
@@ -23,11 +23,12 @@ type ParenExpr struct {
 }
 
 func (e *ParenExpr) Pos() Position { return e.Lparen }
-func (e *ParenExpr) End() Position { return e.Rparen }
+func (e *ParenExpr) End() Position { return e.Rparen.Advance(")") }
 func (e *ParenExpr) node()         {}
 func (e *ParenExpr) expr()         {}
 
 // SelectorExpr represents a selector expression: x.Sel
+// It is also a type when naming a type in another package: pkg.T
 type SelectorExpr struct {
 	X   Expr   // Expression
 	Sel *Ident // Selector
@@ -37,8 +38,10 @@ func (e *SelectorExpr) Pos() Position { return e.X.Pos() }
 func (e *SelectorExpr) End() Position { return e.Sel.End() }
 func (e *SelectorExpr) node()         {}
 func (e *SelectorExpr) expr()         {}
+func (e *SelectorExpr) typeNode()     {}
 
 // IndexExpr represents an index expression: x[i]
+// It is also a type when instantiating a generic type: List[T]
 type IndexExpr struct {
 	X      Expr     // Expression
 	Lbrack Position // Position of "["
@@ -47,9 +50,10 @@ type IndexExpr struct {
 }
 
 func (e *IndexExpr) Pos() Position { return e.X.Pos() }
-func (e *IndexExpr) End() Position { return e.Rbrack }
+func (e *IndexExpr) End() Position { return e.Rbrack.Advance("]") }
 func (e *IndexExpr) node()         {}
 func (e *IndexExpr) expr()         {}
+func (e *IndexExpr) typeNode()     {}
 
 // SliceExpr represents a slice expression: x[low:high] or x[low:high:max]
 type SliceExpr struct {
@@ -63,7 +67,7 @@ type SliceExpr struct {
 }
 
 func (e *SliceExpr) Pos() Position { return e.X.Pos() }
-func (e *SliceExpr) End() Position { return e.Rbrack }
+func (e *SliceExpr) End() Position { return e.Rbrack.Advance("]") }
 func (e *SliceExpr) node()         {}
 func (e *SliceExpr) expr()         {}
 
@@ -77,7 +81,7 @@ type CallExpr struct {
 }
 
 func (e *CallExpr) Pos() Position { return e.Fun.Pos() }
-func (e *CallExpr) End() Position { return e.Rparen }
+func (e *CallExpr) End() Position { return e.Rparen.Advance(")") }
 func (e *CallExpr) node()         {}
 func (e *CallExpr) expr()         {}
 
@@ -144,7 +148,7 @@ func (e *CompositeLit) Pos() Position {
 	}
 	return e.Lbrace
 }
-func (e *CompositeLit) End() Position { return e.Rbrace }
+func (e *CompositeLit) End() Position { return e.Rbrace.Advance("}") }
 func (e *CompositeLit) node()         {}
 func (e *CompositeLit) expr()         {}
 
@@ -170,10 +174,11 @@ func (e *Ellipsis) End() Position {
 	if e.Elt != nil {
 		return e.Elt.End()
 	}
-	return e.Ellipsis
+	return e.Ellipsis.Advance("...")
 }
-func (e *Ellipsis) node() {}
-func (e *Ellipsis) expr() {}
+func (e *Ellipsis) node()     {}
+func (e *Ellipsis) expr()     {}
+func (e *Ellipsis) typeNode() {}
 
 // IndexListExpr represents an index expression with multiple indices (for generics).
 // Example: F[T1, T2, T3]
@@ -185,27 +190,30 @@ type IndexListExpr struct {
 }
 
 func (e *IndexListExpr) Pos() Position { return e.X.Pos() }
-func (e *IndexListExpr) End() Position { return e.Rbrack }
+func (e *IndexListExpr) End() Position { return e.Rbrack.Advance("]") }
 func (e *IndexListExpr) node()         {}
 func (e *IndexListExpr) expr()         {}
+func (e *IndexListExpr) typeNode()     {}
 
 // ============================================================================
 // Moxie-specific Expression Nodes
 // ============================================================================
 
-// ChanLit represents a channel literal (Moxie syntax): &chan T{cap: 10}
+// ChanLit represents a channel literal (Moxie syntax): &chan T{cap: 10},
+// or with an unkeyed capacity &chan T{10}
 type ChanLit struct {
 	Ampersand Position     // Position of "&" (explicit pointer)
-	Chan      Position     // Position of "chan" keyword
+	Chan      Position     // Position of "chan" keyword, or of "<-" in <-chan
 	Dir       ChanDir      // Channel direction
 	Type      Type         // Element type
 	Lbrace    Position     // Position of "{"
-	Cap       Expr         // Capacity expression (in cap: expr)
+	Colon     Position     // Position of ":" after "cap" (invalid if unkeyed)
+	Cap       Expr         // Capacity expression, may be nil
 	Rbrace    Position     // Position of "}"
 }
 
 func (e *ChanLit) Pos() Position { return e.Ampersand }
-func (e *ChanLit) End() Position { return e.Rbrace }
+func (e *ChanLit) End() Position { return e.Rbrace.Advance("}") }
 func (e *ChanLit) node()         {}
 func (e *ChanLit) expr()         {}
 
@@ -220,7 +228,7 @@ type SliceLit struct {
 }
 
 func (e *SliceLit) Pos() Position { return e.Ampersand }
-func (e *SliceLit) End() Position { return e.Rbrace }
+func (e *SliceLit) End() Position { return e.Rbrace.Advance("}") }
 func (e *SliceLit) node()         {}
 func (e *SliceLit) expr()         {}
 
@@ -237,14 +245,48 @@ type MapLit struct {
 }
 
 func (e *MapLit) Pos() Position { return e.Ampersand }
-func (e *MapLit) End() Position { return e.Rbrace }
+func (e *MapLit) End() Position { return e.Rbrace.Advance("}") }
 func (e *MapLit) node()         {}
 func (e *MapLit) expr()         {}
 
+// SetLit represents a set literal (Moxie syntax): &set[T]{a, b}
+type SetLit struct {
+	Ampersand Position // Position of "&" (explicit pointer)
+	Set       Position // Position of "set"
+	Lbrack    Position // Position of "["
+	Elem      Type     // Element type
+	Lbrace    Position // Position of "{"
+	Elts      []Expr   // Elements
+	Rbrace    Position // Position of "}"
+}
+
+func (e *SetLit) Pos() Position { return e.Ampersand }
+func (e *SetLit) End() Position { return e.Rbrace.Advance("}") }
+func (e *SetLit) node()         {}
+func (e *SetLit) expr()         {}
+
+// InterpolatedString represents an interpreted string literal with
+// embedded expressions (Moxie syntax): "hello ${name}". Text holds the
+// literal text around the expressions, one more segment than Exprs, as
+// written in the source but with the escape $${ replaced by ${.
+type InterpolatedString struct {
+	ValuePos Position // Position of the opening quote
+	Value    string   // Literal as written in the source, including quotes
+	Text     []string // Text segments, escape sequences unchanged
+	Exprs    []Expr   // Embedded expressions
+}
+
+func (e *InterpolatedString) Pos() Position { return e.ValuePos }
+func (e *InterpolatedString) End() Position { return e.ValuePos.Advance(e.Value) }
+func (e *InterpolatedString) node()         {}
+func (e *InterpolatedString) expr()         {}
+
 // TypeCoercion represents a type coercion (Moxie FFI feature): (*[]uint32)(bytes)
+// or, with an explicit byte order, (*[]uint32, BigEndian)(bytes)
 type TypeCoercion struct {
 	Lparen Position // Position of "("
 	Target Type     // Target type
+	Endian *Ident   // Byte order (NativeEndian, LittleEndian, BigEndian), may be nil
 	Rparen Position // Position of ")"
 	Expr   Expr     // Expression to coerce
 }
@@ -269,7 +311,7 @@ func (e *FFICall) End() Position {
 	if len(e.Args) > 0 {
 		return e.Args[len(e.Args)-1].End()
 	}
-	return e.Rbrack
+	return e.Rbrack.Advance("]")
 }
 func (e *FFICall) node() {}
 func (e *FFICall) expr() {}
@@ -22,9 +22,7 @@ const (
 )
 
 func (l *BasicLit) Pos() Position { return l.ValuePos }
-func (l *BasicLit) End() Position {
-	return Position{Line: l.ValuePos.Line, Column: l.ValuePos.Column + len(l.Value)}
-}
+func (l *BasicLit) End() Position { return l.ValuePos.Advance(l.Value) }
 func (l *BasicLit) node() {}
 func (l *BasicLit) expr() {}
 
@@ -147,6 +145,8 @@ const (
 	FREE   // free() built-in
 	GROW   // grow() built-in
 	CLEAR  // clear() built-in
+	SORT   // sort() built-in
+	ASSERT // assert() built-in
 
 	DLOPEN  // dlopen() FFI function
 	DLSYM   // dlsym() FFI function
@@ -253,10 +253,12 @@ var tokens = [...]string{
 	TYPE:   "type",
 	VAR:    "var",
 
-	CLONE: "clone",
-	FREE:  "free",
-	GROW:  "grow",
-	CLEAR: "clear",
+	CLONE:  "clone",
+	FREE:   "free",
+	GROW:   "grow",
+	CLEAR:  "clear",
+	SORT:   "sort",
+	ASSERT: "assert",
 
 	DLOPEN:  "dlopen",
 	DLSYM:   "dlsym",
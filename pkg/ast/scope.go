@@ -0,0 +1,60 @@
+package ast
+
+// ============================================================================
+// Scopes
+// ============================================================================
+
+// ObjKind describes what an object names.
+type ObjKind int
+
+const (
+	Bad ObjKind = iota // Unknown
+	Pkg                // Imported package
+	Con                // Constant
+	Typ                // Type
+	Var                // Variable, parameter or result
+	Fun                // Function
+)
+
+// Object is a name declared in a file, as resolved by an analysis that
+// follows the scopes of the file.
+type Object struct {
+	Kind ObjKind
+	Name string      // Declared name
+	Type Type        // Declared or evident type; the signature of a function; may be nil
+	Data interface{} // Data of the analysis, may be nil
+}
+
+// Scope holds the objects declared in a block and links to the scope of
+// the enclosing block. The scopes of a file are not stored in the tree:
+// an analysis builds them as it walks the file, so that a name refers to
+// the objects declared before it.
+type Scope struct {
+	Outer   *Scope
+	Objects map[string]*Object
+}
+
+// NewScope creates a scope nested in outer, which is nil for the scope
+// of a file.
+func NewScope(outer *Scope) *Scope {
+	return &Scope{Outer: outer, Objects: make(map[string]*Object)}
+}
+
+// Lookup returns the object declared with name in s or the nearest
+// enclosing scope, or nil if there is none.
+func (s *Scope) Lookup(name string) *Object {
+	for ; s != nil; s = s.Outer {
+		if obj, ok := s.Objects[name]; ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+// Insert declares obj in s, replacing any object of the same name. The
+// blank identifier is not declared.
+func (s *Scope) Insert(obj *Object) {
+	if obj.Name != "_" {
+		s.Objects[obj.Name] = obj
+	}
+}
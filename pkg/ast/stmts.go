@@ -32,7 +32,7 @@ type EmptyStmt struct {
 }
 
 func (s *EmptyStmt) Pos() Position { return s.Semicolon }
-func (s *EmptyStmt) End() Position { return s.Semicolon }
+func (s *EmptyStmt) End() Position { return s.Semicolon.Advance(";") }
 func (s *EmptyStmt) node()         {}
 func (s *EmptyStmt) stmt()         {}
 
@@ -78,7 +78,7 @@ type IncDecStmt struct {
 }
 
 func (s *IncDecStmt) Pos() Position { return s.X.Pos() }
-func (s *IncDecStmt) End() Position { return s.TokPos }
+func (s *IncDecStmt) End() Position { return s.TokPos.Advance(s.Tok.String()) }
 func (s *IncDecStmt) node()         {}
 func (s *IncDecStmt) stmt()         {}
 
@@ -128,7 +128,7 @@ func (s *ReturnStmt) End() Position {
 	if len(s.Results) > 0 {
 		return s.Results[len(s.Results)-1].End()
 	}
-	return s.Return
+	return s.Return.Advance("return")
 }
 func (s *ReturnStmt) node() {}
 func (s *ReturnStmt) stmt() {}
@@ -145,7 +145,7 @@ func (s *BranchStmt) End() Position {
 	if s.Label != nil {
 		return s.Label.End()
 	}
-	return s.TokPos
+	return s.TokPos.Advance(s.Tok.String())
 }
 func (s *BranchStmt) node() {}
 func (s *BranchStmt) stmt() {}
@@ -158,7 +158,7 @@ type BlockStmt struct {
 }
 
 func (s *BlockStmt) Pos() Position { return s.Lbrace }
-func (s *BlockStmt) End() Position { return s.Rbrace }
+func (s *BlockStmt) End() Position { return s.Rbrace.Advance("}") }
 func (s *BlockStmt) node()         {}
 func (s *BlockStmt) stmt()         {}
 
@@ -17,7 +17,7 @@ type Ident struct {
 }
 
 func (i *Ident) Pos() Position { return i.NamePos }
-func (i *Ident) End() Position { return Position{Line: i.NamePos.Line, Column: i.NamePos.Column + len(i.Name)} }
+func (i *Ident) End() Position { return i.NamePos.Advance(i.Name) }
 func (i *Ident) node()         {}
 func (i *Ident) expr()         {}
 func (i *Ident) typeNode()     {}
@@ -144,7 +144,7 @@ type StructType struct {
 }
 
 func (t *StructType) Pos() Position { return t.Struct }
-func (t *StructType) End() Position { return t.Rbrace }
+func (t *StructType) End() Position { return t.Rbrace.Advance("}") }
 func (t *StructType) node()         {}
 func (t *StructType) expr()         {}
 func (t *StructType) typeNode()     {}
@@ -158,7 +158,7 @@ type InterfaceType struct {
 }
 
 func (t *InterfaceType) Pos() Position { return t.Interface }
-func (t *InterfaceType) End() Position { return t.Rbrace }
+func (t *InterfaceType) End() Position { return t.Rbrace.Advance("}") }
 func (t *InterfaceType) node()         {}
 func (t *InterfaceType) expr()         {}
 func (t *InterfaceType) typeNode()     {}
@@ -211,7 +211,7 @@ func (f *FieldList) Pos() Position {
 }
 func (f *FieldList) End() Position {
 	if f.Closing.IsValid() {
-		return f.Closing
+		return f.Closing.Advance(")") // All closing delimiters are one byte
 	}
 	if n := len(f.List); n > 0 {
 		return f.List[n-1].End()
@@ -222,9 +222,11 @@ func (f *FieldList) node() {}
 
 // Field represents a field in a struct, interface, or function parameter/result list.
 type Field struct {
-	Names []*Ident // Field names (may be empty for anonymous fields or unnamed parameters)
-	Type  Type     // Field type
-	Tag   *BasicLit // Field tag (for struct fields only, may be nil)
+	Doc     *CommentGroup // Associated documentation, may be nil
+	Names   []*Ident      // Field names (may be empty for anonymous fields or unnamed parameters)
+	Type    Type          // Field type
+	Tag     *BasicLit     // Field tag (for struct fields only, may be nil)
+	Comment *CommentGroup // Line comment, may be nil
 }
 
 func (f *Field) Pos() Position {
@@ -258,11 +260,48 @@ type ParenType struct {
 }
 
 func (t *ParenType) Pos() Position { return t.Lparen }
-func (t *ParenType) End() Position { return t.Rparen }
+func (t *ParenType) End() Position { return t.Rparen.Advance(")") }
 func (t *ParenType) node()         {}
 func (t *ParenType) expr()         {}
 func (t *ParenType) typeNode()     {}
 
+// TildeType represents an underlying-type term in a constraint: ~T
+type TildeType struct {
+	Tilde Position // Position of "~"
+	Type  Type     // Type
+}
+
+func (t *TildeType) Pos() Position { return t.Tilde }
+func (t *TildeType) End() Position { return t.Type.End() }
+func (t *TildeType) node()         {}
+func (t *TildeType) expr()         {}
+func (t *TildeType) typeNode()     {}
+
+// ConstType represents a read-only view of a type: const T. Values of
+// type const T cannot be assigned, nor modified through a pointer, slice,
+// map or field reached from them.
+type ConstType struct {
+	Const Position // Position of "const" keyword
+	Type  Type     // Qualified type
+}
+
+func (t *ConstType) Pos() Position { return t.Const }
+func (t *ConstType) End() Position { return t.Type.End() }
+func (t *ConstType) node()         {}
+func (t *ConstType) expr()         {}
+func (t *ConstType) typeNode()     {}
+
+// UnionType represents a union of type terms in a constraint: ~int | ~float64
+type UnionType struct {
+	Terms []Type // Terms (at least two)
+}
+
+func (t *UnionType) Pos() Position { return t.Terms[0].Pos() }
+func (t *UnionType) End() Position { return t.Terms[len(t.Terms)-1].End() }
+func (t *UnionType) node()         {}
+func (t *UnionType) expr()         {}
+func (t *UnionType) typeNode()     {}
+
 // TypeAssertExpr represents a type assertion: x.(T)
 type TypeAssertExpr struct {
 	X      Expr     // Expression being asserted
@@ -272,6 +311,6 @@ type TypeAssertExpr struct {
 }
 
 func (e *TypeAssertExpr) Pos() Position { return e.X.Pos() }
-func (e *TypeAssertExpr) End() Position { return e.Rparen }
+func (e *TypeAssertExpr) End() Position { return e.Rparen.Advance(")") }
 func (e *TypeAssertExpr) node()         {}
 func (e *TypeAssertExpr) expr()         {}
@@ -0,0 +1,451 @@
+package ast
+
+// ============================================================================
+// Traversal
+// ============================================================================
+
+// Visitor is called by Walk for each node. If Visit returns a non-nil
+// visitor w, Walk visits each child of node with w, followed by a call of
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, like go/ast.Walk. It starts
+// by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Comments
+	case *Comment:
+		// nothing to do
+
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	// File and declarations
+	case *File:
+		walkComments(v, n.Doc)
+		if n.Package != nil {
+			Walk(v, n.Package)
+		}
+		for _, d := range n.Imports {
+			Walk(v, d)
+		}
+		walkDecls(v, n.Decls)
+		// Comments are not walked; they are reached through Doc and
+		// Comment fields.
+
+	case *PackageClause:
+		walkIdent(v, n.Name)
+
+	case *ImportDecl:
+		walkComments(v, n.Doc)
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *ImportSpec:
+		walkComments(v, n.Doc)
+		walkIdent(v, n.Name)
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+		walkComments(v, n.Comment)
+
+	case *ConstDecl:
+		walkComments(v, n.Doc)
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *ConstSpec:
+		walkComments(v, n.Doc)
+		walkIdents(v, n.Names)
+		walkNode(v, n.Type)
+		walkExprs(v, n.Values)
+		walkComments(v, n.Comment)
+
+	case *VarDecl:
+		walkComments(v, n.Doc)
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *VarSpec:
+		walkComments(v, n.Doc)
+		walkIdents(v, n.Names)
+		walkNode(v, n.Type)
+		walkExprs(v, n.Values)
+		walkComments(v, n.Comment)
+
+	case *TypeDecl:
+		walkComments(v, n.Doc)
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *TypeSpec:
+		walkComments(v, n.Doc)
+		walkIdent(v, n.Name)
+		walkFields(v, n.TypeParams)
+		walkNode(v, n.Type)
+		walkComments(v, n.Comment)
+
+	case *FuncDecl:
+		walkComments(v, n.Doc)
+		walkFields(v, n.Recv)
+		walkIdent(v, n.Name)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	// Types
+	case *Ident, *BasicType:
+		// nothing to do
+
+	case *PointerType:
+		walkNode(v, n.Base)
+
+	case *SliceType:
+		walkNode(v, n.Elem)
+
+	case *ArrayType:
+		walkNode(v, n.Len)
+		walkNode(v, n.Elem)
+
+	case *MapType:
+		walkNode(v, n.Key)
+		walkNode(v, n.Value)
+
+	case *ChanType:
+		walkNode(v, n.Value)
+
+	case *StructType:
+		walkFields(v, n.Fields)
+
+	case *InterfaceType:
+		walkFields(v, n.Methods)
+
+	case *FuncType:
+		walkFields(v, n.TypeParams)
+		walkFields(v, n.Params)
+		walkFields(v, n.Results)
+
+	case *FieldList:
+		for _, f := range n.List {
+			Walk(v, f)
+		}
+
+	case *Field:
+		walkComments(v, n.Doc)
+		walkIdents(v, n.Names)
+		walkNode(v, n.Type)
+		if n.Tag != nil {
+			Walk(v, n.Tag)
+		}
+		walkComments(v, n.Comment)
+
+	case *ParenType:
+		walkNode(v, n.X)
+
+	case *TildeType:
+		walkNode(v, n.Type)
+
+	case *ConstType:
+		walkNode(v, n.Type)
+
+	case *UnionType:
+		for _, t := range n.Terms {
+			walkNode(v, t)
+		}
+
+	// Expressions
+	case *BadExpr, *BasicLit:
+		// nothing to do
+
+	case *ParenExpr:
+		walkNode(v, n.X)
+
+	case *SelectorExpr:
+		walkNode(v, n.X)
+		walkIdent(v, n.Sel)
+
+	case *IndexExpr:
+		walkNode(v, n.X)
+		walkNode(v, n.Index)
+
+	case *IndexListExpr:
+		walkNode(v, n.X)
+		walkExprs(v, n.Indices)
+
+	case *SliceExpr:
+		walkNode(v, n.X)
+		walkNode(v, n.Low)
+		walkNode(v, n.High)
+		walkNode(v, n.Max)
+
+	case *TypeAssertExpr:
+		walkNode(v, n.X)
+		walkNode(v, n.Type)
+
+	case *CallExpr:
+		walkNode(v, n.Fun)
+		walkExprs(v, n.Args)
+
+	case *StarExpr:
+		walkNode(v, n.X)
+
+	case *UnaryExpr:
+		walkNode(v, n.X)
+
+	case *BinaryExpr:
+		walkNode(v, n.X)
+		walkNode(v, n.Y)
+
+	case *KeyValueExpr:
+		walkNode(v, n.Key)
+		walkNode(v, n.Value)
+
+	case *CompositeLit:
+		walkNode(v, n.Type)
+		walkExprs(v, n.Elts)
+
+	case *FuncLit:
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *Ellipsis:
+		walkNode(v, n.Elt)
+
+	case *ChanLit:
+		walkNode(v, n.Type)
+		walkNode(v, n.Cap)
+
+	case *SliceLit:
+		walkNode(v, n.Type)
+		walkExprs(v, n.Elts)
+
+	case *MapLit:
+		walkNode(v, n.Key)
+		walkNode(v, n.Value)
+		walkExprs(v, n.Elts)
+
+	case *SetLit:
+		walkNode(v, n.Elem)
+		walkExprs(v, n.Elts)
+
+	case *InterpolatedString:
+		walkExprs(v, n.Exprs)
+
+	case *TypeCoercion:
+		walkNode(v, n.Target)
+		walkIdent(v, n.Endian)
+		walkNode(v, n.Expr)
+
+	case *FFICall:
+		walkIdent(v, n.Name)
+		walkNode(v, n.Type)
+		walkExprs(v, n.Args)
+
+	// Statements
+	case *BadStmt, *EmptyStmt:
+		// nothing to do
+
+	case *DeclStmt:
+		walkNode(v, n.Decl)
+
+	case *LabeledStmt:
+		walkIdent(v, n.Label)
+		walkNode(v, n.Stmt)
+
+	case *ExprStmt:
+		walkNode(v, n.X)
+
+	case *SendStmt:
+		walkNode(v, n.Chan)
+		walkNode(v, n.Value)
+
+	case *IncDecStmt:
+		walkNode(v, n.X)
+
+	case *AssignStmt:
+		walkExprs(v, n.Lhs)
+		walkExprs(v, n.Rhs)
+
+	case *GoStmt:
+		if n.Call != nil {
+			Walk(v, n.Call)
+		}
+
+	case *DeferStmt:
+		if n.Call != nil {
+			Walk(v, n.Call)
+		}
+
+	case *ReturnStmt:
+		walkExprs(v, n.Results)
+
+	case *BranchStmt:
+		walkIdent(v, n.Label)
+
+	case *BlockStmt:
+		walkStmts(v, n.List)
+
+	case *IfStmt:
+		walkNode(v, n.Init)
+		walkNode(v, n.Cond)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+		walkNode(v, n.Else)
+
+	case *CaseClause:
+		walkExprs(v, n.List)
+		walkStmts(v, n.Body)
+
+	case *SwitchStmt:
+		walkNode(v, n.Init)
+		walkNode(v, n.Tag)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *TypeSwitchStmt:
+		walkNode(v, n.Init)
+		walkNode(v, n.Assign)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *CommClause:
+		walkNode(v, n.Comm)
+		walkStmts(v, n.Body)
+
+	case *SelectStmt:
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *ForStmt:
+		walkNode(v, n.Init)
+		walkNode(v, n.Cond)
+		walkNode(v, n.Post)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *RangeStmt:
+		walkNode(v, n.Key)
+		walkNode(v, n.Value)
+		walkNode(v, n.X)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// walkNode walks node unless it is nil. Interface fields holding a nil
+// pointer are skipped as well, since the builder leaves absent parts nil.
+func walkNode(v Visitor, node Node) {
+	if node != nil && !isNilNode(node) {
+		Walk(v, node)
+	}
+}
+
+// isNilNode reports whether node is a typed nil pointer of one of the
+// node types that commonly appear in interface fields.
+func isNilNode(node Node) bool {
+	switch n := node.(type) {
+	case *Ident:
+		return n == nil
+	case *BlockStmt:
+		return n == nil
+	case *IfStmt:
+		return n == nil
+	case *CallExpr:
+		return n == nil
+	case *FuncType:
+		return n == nil
+	case *FieldList:
+		return n == nil
+	case *CommentGroup:
+		return n == nil
+	case *BasicLit:
+		return n == nil
+	}
+	return false
+}
+
+func walkIdent(v Visitor, ident *Ident) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkIdents(v Visitor, list []*Ident) {
+	for _, x := range list {
+		walkIdent(v, x)
+	}
+}
+
+func walkExprs(v Visitor, list []Expr) {
+	for _, x := range list {
+		walkNode(v, x)
+	}
+}
+
+func walkStmts(v Visitor, list []Stmt) {
+	for _, x := range list {
+		walkNode(v, x)
+	}
+}
+
+func walkDecls(v Visitor, list []Decl) {
+	for _, x := range list {
+		walkNode(v, x)
+	}
+}
+
+func walkFields(v Visitor, list *FieldList) {
+	if list != nil {
+		Walk(v, list)
+	}
+}
+
+func walkComments(v Visitor, group *CommentGroup) {
+	if group != nil {
+		Walk(v, group)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
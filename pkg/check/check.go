@@ -0,0 +1,166 @@
+// Package check validates Moxie ASTs.
+//
+// The parser accepts the whole Go syntax, including constructs that Moxie
+// removes or gives no meaning, such as make and append or slice literals
+// without an explicit pointer. File reports these with their source range
+// and, where there is one, the Moxie equivalent as a suggested fix. It
+// also reports writes through const declarations and const types, and
+// double frees, uses after free and frees of escaping values.
+package check
+
+import (
+	"bytes"
+
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+	"github.com/mleku/moxie/pkg/printer"
+)
+
+// File checks file for Go constructs without Moxie meaning, writes
+// through read-only views and misplaced frees, and returns the
+// diagnostics in source order.
+func File(file *ast.File) diag.List {
+	c := &checker{nested: make(map[ast.Expr]bool)}
+	ast.Inspect(file, c.node)
+	c.constness(file)
+	c.frees(file)
+	c.diags.Sort()
+	return c.diags
+}
+
+// checker holds the state of a File call.
+type checker struct {
+	diags  diag.List
+	nested map[ast.Expr]bool   // Literals that are operands of & or elements of a literal
+	types  map[string]ast.Type // Types declared in the file, by name
+
+	freeSeen map[string]bool // Free diagnostics already reported
+}
+
+// node checks n and records the literals whose form follows from n.
+func (c *checker) node(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.UnaryExpr:
+		if n.Op == ast.AND {
+			c.nested[n.X] = true
+		}
+	case *ast.CompositeLit:
+		c.elements(n.Elts)
+		c.literal(n)
+	case *ast.SliceLit:
+		c.elements(n.Elts)
+	case *ast.MapLit:
+		c.elements(n.Elts)
+	case *ast.SetLit:
+		c.elements(n.Elts)
+	}
+	return true
+}
+
+// elements marks the elements of a composite literal, whose type is the
+// element type of the literal.
+func (c *checker) elements(elts []ast.Expr) {
+	for _, e := range elts {
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			c.nested[kv.Key] = true
+			c.nested[kv.Value] = true
+		} else {
+			c.nested[e] = true
+		}
+	}
+}
+
+// literal reports slice, map and channel literals that are values rather
+// than pointers.
+func (c *checker) literal(x *ast.CompositeLit) {
+	if c.nested[x] {
+		return
+	}
+	kind := referenceKind(x.Type)
+	if kind == "" {
+		return
+	}
+	c.report(x, "%s literal without &: Moxie %ss are pointers", kind, kind).
+		Suggest("take its address", c.source(&ast.UnaryExpr{Op: ast.AND, X: x}))
+}
+
+// call reports calls of the removed builtins make and append, and of new
+// with a slice, map or channel type. The caller resolves the name of the
+// function: a call of a declaration named make is not reported.
+func (c *checker) call(x *ast.CallExpr) {
+	fun, ok := x.Fun.(*ast.Ident)
+	if !ok || len(x.Args) == 0 {
+		return
+	}
+
+	switch fun.Name {
+	case "make":
+		d := c.report(x, "make is not a Moxie builtin")
+		if lit := makeLiteral(x); lit != nil {
+			d.Suggest("use a literal", c.source(lit))
+		}
+	case "append":
+		d := c.report(x, "append is not a Moxie builtin")
+		if len(x.Args) == 2 && x.Ellipsis.IsValid() {
+			d.Suggest("concatenate with |", c.source(&ast.BinaryExpr{X: x.Args[0], Op: ast.OR, Y: x.Args[1]}))
+		} else {
+			d.Suggest("concatenate with |", "")
+		}
+	case "new":
+		if kind := referenceKind(x.Args[0]); kind != "" {
+			d := c.report(x, "new(%s) returns a pointer to a nil %s", c.source(x.Args[0]), kind)
+			d.Severity = diag.Warning
+			d.Suggest("use a literal", c.source(makeLiteral(&ast.CallExpr{Fun: fun, Args: x.Args[:1]})))
+		}
+	}
+}
+
+// referenceKind returns the kind of t if it is a slice, map or channel
+// type, which Moxie always uses through pointers, or "".
+func referenceKind(t ast.Expr) string {
+	switch t.(type) {
+	case *ast.SliceType:
+		return "slice"
+	case *ast.MapType:
+		return "map"
+	case *ast.ChanType:
+		return "channel"
+	}
+	return ""
+}
+
+// makeLiteral returns the Moxie literal equivalent to the make call x, or
+// nil if there is none. The length of a slice is dropped: Moxie slices
+// grow with grow.
+func makeLiteral(x *ast.CallExpr) ast.Expr {
+	switch t := x.Args[0].(type) {
+	case *ast.SliceType:
+		return &ast.UnaryExpr{Op: ast.AND, X: &ast.CompositeLit{Type: t}}
+	case *ast.MapType:
+		return &ast.MapLit{Key: t.Key, Value: t.Value}
+	case *ast.ChanType:
+		lit := &ast.ChanLit{Dir: t.Dir, Type: t.Value}
+		if len(x.Args) > 1 {
+			lit.Cap = x.Args[1]
+		}
+		return lit
+	}
+	return nil
+}
+
+// report adds an error diagnostic for the range of n.
+func (c *checker) report(n ast.Node, format string, args ...interface{}) *diag.Diagnostic {
+	d := diag.Errorf(n.Pos(), diag.Unsupported, format, args...)
+	d.End = n.End()
+	c.diags.Add(d)
+	return d
+}
+
+// source returns n printed as Moxie source, or "" if it cannot be printed.
+func (c *checker) source(n ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, n); err != nil {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,292 @@
+package check_test
+
+import (
+	"testing"
+
+	"github.com/mleku/moxie/pkg/antlr"
+	"github.com/mleku/moxie/pkg/check"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+func TestFile(t *testing.T) {
+	src := `package main
+
+func f(s *[]int32, t *[]int32) {
+	a := make([]int32, 10)
+	b := make(map[string]int32)
+	c := make(chan int32, 4)
+	*s = append(*s, 1, 2)
+	*s = append(*s, *t...)
+	d := []int32{1, 2}
+	e := new(map[string]bool)
+	ok := &[][]int32{[]int32{1}, {2}}
+	m := &map[string]*[]int32{"a": &[]int32{1}}
+	p := new(int32)
+}
+
+func g(append func(*[]int32, int32) *[]int32, s *[]int32) {
+	make := func(n int32) int32 { return n }
+	s = append(s, make(1))
+}
+`
+	file, diags := antlr.ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+
+	tests := []struct {
+		line     int
+		severity diag.Severity
+		message  string
+		fix      string
+	}{
+		{4, diag.Error, "make is not a Moxie builtin", "&[]int32{}"},
+		{5, diag.Error, "make is not a Moxie builtin", "&map[string]int32{}"},
+		{6, diag.Error, "make is not a Moxie builtin", "&chan int32{cap: 4}"},
+		{7, diag.Error, "append is not a Moxie builtin", ""},
+		{8, diag.Error, "append is not a Moxie builtin", "*s | *t"},
+		{9, diag.Error, "slice literal without &: Moxie slices are pointers", "&[]int32{1, 2}"},
+		{10, diag.Warning, "new(map[string]bool) returns a pointer to a nil map", "&map[string]bool{}"},
+	}
+	got := check.File(file)
+	if len(got) != len(tests) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(tests), len(got), got)
+	}
+	for i, tt := range tests {
+		d := got[i]
+		if d.Pos.Line != tt.line || d.Severity != tt.severity || d.Code != diag.Unsupported || d.Message != tt.message {
+			t.Errorf("diagnostic %d: got %v", i, d)
+		}
+		if d.Pos.Column != 7 || d.End.Line != tt.line {
+			t.Errorf("diagnostic %d: range %v-%v", i, d.Pos, d.End)
+		}
+		if len(d.Suggestions) != 1 || d.Suggestions[0].Replacement != tt.fix {
+			t.Errorf("diagnostic %d: suggestions %+v, want %q", i, d.Suggestions, tt.fix)
+		}
+	}
+}
+
+func TestConst(t *testing.T) {
+	src := `package main
+
+const Limit = 10
+const Config = &map[string]int32{"a": 1}
+
+type Buffer struct {
+	data const *[]byte
+	name const string
+	n    int32
+}
+
+func fill(dst *[]byte, src const *[]byte) {
+	(*dst)[0] = (*src)[0]
+}
+
+func f(p const *[]byte, b *Buffer, q *[]byte) {
+	Limit = 11
+	Limit++
+	Config["a"] = 2
+	m := Config
+	m["b"] = 3
+	(*p)[0] = 1
+	p = q
+	r := p
+	r = q
+	(*r)[1] = 2
+	b.n = 1
+	b.name = "x"
+	var w *[]byte = p
+	fill(p, p)
+	clear(p)
+	n := (*p)[0]
+	n = 2
+	for _, x := range *p {
+		x = 1
+	}
+	if Limit := 3; Limit > 0 {
+		Limit = 4
+	}
+	q = r
+}
+
+const Bytes = &[]byte{1}
+
+var buf *[]byte = Bytes
+
+func first(dst *[]byte) byte {
+	return (*dst)[0]
+}
+
+func g(p const *[]byte) {
+	{
+		p := first(p)
+		println(p)
+	}
+}
+`
+	file, diags := antlr.ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+
+	tests := []struct {
+		line    int
+		message string
+	}{
+		{17, "cannot assign to const Limit"},
+		{18, "cannot increment const Limit"},
+		{19, `cannot assign to Config["a"] (Config is const)`},
+		{21, `cannot assign to m["b"] (Config is const)`},
+		{22, "cannot assign to (*p)[0] (p is const)"},
+		{23, "cannot assign to const p"},
+		{26, "cannot assign to (*r)[1] (p is const)"},
+		{28, "cannot assign to const b.name"},
+		{29, "cannot assign p to mutable w (p is const)"},
+		{30, "cannot pass p to mutable parameter of fill (p is const)"},
+		{31, "cannot clear const p"},
+		{40, "cannot assign r to mutable q (p is const)"},
+		{45, "cannot assign Bytes to mutable buf (Bytes is const)"},
+		{53, "cannot pass p to mutable parameter of first (p is const)"},
+	}
+	got := check.File(file)
+	if len(got) != len(tests) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(tests), len(got), got)
+	}
+	for i, tt := range tests {
+		d := got[i]
+		if d.Pos.Line != tt.line || d.Severity != diag.Error || d.Code != diag.ConstMutation || d.Message != tt.message {
+			t.Errorf("diagnostic %d: got %v", i, d)
+		}
+	}
+}
+
+func TestFree(t *testing.T) {
+	src := `package main
+
+func twice(x *[]byte) {
+	free(x)
+	free(x)
+	_ = (*x)[0]
+}
+
+func branches(c bool) {
+	x := &[]byte{1}
+	if c {
+		free(x)
+	} else {
+		free(x)
+	}
+	println(x)
+	y := &[]byte{}
+	if c {
+		free(y)
+		return
+	}
+	println(y)
+}
+
+func loops(ch *chan *[]byte) {
+	x := &[]byte{}
+	for i := 0; i < 10; i++ {
+		free(x)
+	}
+	for i := 0; i < 10; i++ {
+		x = &[]byte{}
+		free(x)
+	}
+	y := &[]byte{}
+	*ch <- y
+	free(y)
+}
+
+func deferred() *[]byte {
+	x := &[]byte{}
+	defer free(x)
+	y := &[]byte{}
+	defer func() {
+		recover()
+		free(y)
+	}()
+	free(y)
+	return x
+}
+
+func closures() {
+	go func() {
+		z := &[]byte{}
+		free(z)
+		println(z)
+	}()
+	for _, s := range *(&[]*[]byte{}) {
+		free(s)
+	}
+}
+
+func breaks(x int32, p *[]byte, q *[]byte) {
+	switch x {
+	case 1:
+		break
+	default:
+		free(p)
+	}
+	println(p)
+	switch x {
+	case 1:
+		free(q)
+		break
+	default:
+		free(q)
+	}
+	println(q)
+	r := &[]byte{}
+	for {
+		free(r)
+		break
+	}
+	println(r)
+	s := &[]byte{}
+	for x > 0 {
+		free(s)
+		break
+	}
+	println(s)
+}
+
+func local(p *[]byte) {
+	free := func(q *[]byte) {}
+	free(p)
+	free(p)
+	println(p)
+}
+`
+	file, diags := antlr.ParseFile("test.mx", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected errors:\n%v", diags)
+	}
+
+	tests := []struct {
+		line    int
+		message string
+	}{
+		{5, "double free of x: already freed on line 4"},
+		{6, "use of x after free on line 4"},
+		{16, "use of x after free on line 12"},
+		{28, "double free of x: freed on line 28 in the previous iteration"},
+		{36, "free of y, which escapes by send on line 35"},
+		{47, "double free of y: also freed by defer on line 45"},
+		{48, "x escapes by return but is freed by defer on line 41"},
+		{55, "use of z after free on line 54"},
+		{77, "use of q after free on line 75"},
+		{83, "use of r after free on line 80"},
+	}
+	got := check.File(file)
+	if len(got) != len(tests) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(tests), len(got), got)
+	}
+	for i, tt := range tests {
+		d := got[i]
+		if d.Pos.Line != tt.line || d.Severity != diag.Error || d.Code != diag.FreeMisuse || d.Message != tt.message {
+			t.Errorf("diagnostic %d: got %v", i, d)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package check
+
+import (
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// Constness
+//
+// A const declaration and a value of type const T are read-only views:
+// they cannot be assigned, nor modified through an index, field, pointer
+// indirection or mutating builtin. Constness follows references: a
+// variable defined from a read-only pointer, slice, map or channel is
+// itself a read-only view, and may not be stored in a mutable variable or
+// passed for a mutable parameter of a function declared in the file.
+//
+// Names are resolved by scope within the file. Types are known only where
+// the file declares them, so a write through an expression of unknown
+// type is never reported.
+
+// The objects of the scopes have the declared or inferred types of the
+// names, and the read-only expression a variable was defined from, if
+// any, as their Data.
+
+// viewOf returns the read-only expression the variable obj was defined
+// from, or nil.
+func viewOf(obj *ast.Object) ast.Expr {
+	ro, _ := obj.Data.(ast.Expr)
+	return ro
+}
+
+// binding reports whether the name of obj itself cannot be assigned.
+func (c *checker) binding(obj *ast.Object) bool {
+	return obj.Kind == ast.Con || viewOf(obj) == nil && c.readOnly(obj.Type)
+}
+
+// signature returns the signature of the function obj, or nil.
+func signature(obj *ast.Object) *ast.FuncType {
+	if obj == nil || obj.Kind != ast.Fun {
+		return nil
+	}
+	t, _ := obj.Type.(*ast.FuncType)
+	return t
+}
+
+// mutatingBuiltins maps the builtins that modify their first argument to
+// the verb describing it.
+var mutatingBuiltins = map[string]string{
+	"clear":  "clear",
+	"copy":   "copy into",
+	"delete": "delete from",
+	"free":   "free",
+	"grow":   "grow",
+	"sort":   "sort",
+}
+
+// constVisitor checks writes in a scope.
+type constVisitor struct {
+	c     *checker
+	scope *ast.Scope
+}
+
+// constness reports writes through const declarations and types in file.
+func (c *checker) constness(file *ast.File) {
+	c.types = make(map[string]ast.Type)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.TypeSpec); ok && spec.Name != nil {
+			c.types[spec.Name.Name] = spec.Type
+		}
+		return true
+	})
+
+	v := &constVisitor{c: c, scope: ast.NewScope(nil)}
+	for _, d := range file.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name != nil {
+			v.scope.Insert(&ast.Object{Kind: ast.Fun, Name: fn.Name.Name, Type: fn.Type})
+		} else {
+			v.declare(d)
+		}
+	}
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			for _, spec := range d.Specs {
+				v.walkValues(spec.Values)
+			}
+		case *ast.VarDecl:
+			for _, spec := range d.Specs {
+				v.walkValues(spec.Values)
+			}
+		default:
+			ast.Walk(v, d)
+		}
+	}
+}
+
+// walkValues walks the values of a spec or assignment, which may be nil.
+func (v *constVisitor) walkValues(values []ast.Expr) {
+	for _, x := range values {
+		if x != nil {
+			ast.Walk(v, x)
+		}
+	}
+}
+
+// open returns a visitor for a scope nested in v's.
+func (v *constVisitor) open() *constVisitor {
+	return &constVisitor{c: v.c, scope: ast.NewScope(v.scope)}
+}
+
+func (v *constVisitor) Visit(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		inner := v.open()
+		inner.fields(n.Recv)
+		inner.signature(n.Type)
+		return inner
+	case *ast.FuncLit:
+		inner := v.open()
+		inner.signature(n.Type)
+		return inner
+	case *ast.BlockStmt, *ast.IfStmt, *ast.ForStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt,
+		*ast.SelectStmt, *ast.CaseClause, *ast.CommClause:
+		return v.open()
+	case *ast.RangeStmt:
+		// The variables are declared after the range expression.
+		v.walkValues([]ast.Expr{n.X, n.Key, n.Value})
+		inner := v.open()
+		v.rangeVars(inner, n)
+		if n.Body != nil {
+			ast.Walk(inner, n.Body)
+		}
+		return nil
+	case *ast.TypeDecl:
+		v.declare(n)
+	case *ast.ConstSpec:
+		v.walkValues(n.Values)
+		v.declare(n)
+		return nil
+	case *ast.VarSpec:
+		v.walkValues(n.Values)
+		v.declare(n)
+		return nil
+	case *ast.AssignStmt:
+		// The names a short variable declaration introduces are declared
+		// after the values.
+		v.walkValues(n.Rhs)
+		v.assign(n)
+		v.walkValues(n.Lhs)
+		return nil
+	case *ast.IncDecStmt:
+		verb := "increment"
+		if n.Tok == ast.DEC {
+			verb = "decrement"
+		}
+		v.write(n.X, verb)
+	case *ast.CallExpr:
+		v.call(n)
+	}
+	return v
+}
+
+// declare adds the names of a type, const or var declaration or spec to
+// the scope.
+func (v *constVisitor) declare(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.TypeDecl:
+		for _, spec := range n.Specs {
+			if spec.Name != nil {
+				v.scope.Insert(&ast.Object{Kind: ast.Typ, Name: spec.Name.Name})
+			}
+		}
+	case *ast.ConstDecl:
+		for _, spec := range n.Specs {
+			v.declare(spec)
+		}
+	case *ast.VarDecl:
+		for _, spec := range n.Specs {
+			v.declare(spec)
+		}
+	case *ast.ConstSpec:
+		for i, name := range n.Names {
+			obj := &ast.Object{Kind: ast.Con, Name: name.Name, Type: n.Type}
+			if obj.Type == nil && i < len(n.Values) {
+				obj.Type, _ = v.view(n.Values[i])
+			}
+			v.scope.Insert(obj)
+		}
+	case *ast.VarSpec:
+		for i, name := range n.Names {
+			var value ast.Expr
+			if len(n.Values) == len(n.Names) {
+				value = n.Values[i]
+			}
+			if n.Type != nil {
+				if value != nil {
+					v.store(value, n.Type, name)
+				}
+				v.define(name, &ast.Object{Kind: ast.Var, Type: n.Type})
+			} else {
+				v.define(name, v.alias(value))
+			}
+		}
+	}
+}
+
+// signature declares the parameters and results of t.
+func (v *constVisitor) signature(t *ast.FuncType) {
+	if t != nil {
+		v.fields(t.Params)
+		v.fields(t.Results)
+	}
+}
+
+// fields declares the names of a receiver, parameter or result list.
+func (v *constVisitor) fields(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, f := range list.List {
+		for _, name := range f.Names {
+			v.define(name, &ast.Object{Kind: ast.Var, Type: f.Type})
+		}
+	}
+}
+
+// rangeVars declares the variables of a range clause in inner, or checks
+// them as assignment targets.
+func (v *constVisitor) rangeVars(inner *constVisitor, n *ast.RangeStmt) {
+	if n.Tok != ast.DEFINE {
+		for _, x := range []ast.Expr{n.Key, n.Value} {
+			if x != nil {
+				v.write(x, "assign to")
+			}
+		}
+		return
+	}
+
+	t, ro := v.view(n.X)
+	var key, value ast.Type
+	switch u := v.c.container(t).(type) {
+	case *ast.MapType:
+		key, value = u.Key, u.Value
+	case *ast.SliceType:
+		value = u.Elem
+	case *ast.ArrayType:
+		value = u.Elem
+	}
+	for _, x := range []struct {
+		expr ast.Expr
+		typ  ast.Type
+	}{{n.Key, key}, {n.Value, value}} {
+		if id, ok := x.expr.(*ast.Ident); ok {
+			inner.define(id, v.derive(x.typ, ro))
+		}
+	}
+}
+
+// assign checks the targets of an assignment, and declares the names a
+// short variable declaration introduces.
+func (v *constVisitor) assign(n *ast.AssignStmt) {
+	defined := make(map[*ast.Ident]*ast.Object)
+	for i, lhs := range n.Lhs {
+		var rhs ast.Expr
+		if len(n.Rhs) == len(n.Lhs) {
+			rhs = n.Rhs[i]
+		}
+		if n.Tok == ast.DEFINE {
+			if id, ok := lhs.(*ast.Ident); ok {
+				if _, redeclared := v.scope.Objects[id.Name]; !redeclared {
+					defined[id] = v.alias(rhs)
+					continue
+				}
+			}
+		}
+		if !v.write(lhs, "assign to") && rhs != nil && (n.Tok == ast.ASSIGN || n.Tok == ast.DEFINE) {
+			if t, _ := v.view(lhs); t != nil {
+				v.store(rhs, t, lhs)
+			}
+		}
+	}
+	for id, obj := range defined {
+		v.define(id, obj)
+	}
+}
+
+// define declares obj in the scope under the name id.
+func (v *constVisitor) define(id *ast.Ident, obj *ast.Object) {
+	obj.Name = id.Name
+	v.scope.Insert(obj)
+}
+
+// call checks the calls of builtins and the arguments of functions
+// declared in the file.
+func (v *constVisitor) call(n *ast.CallExpr) {
+	fun, ok := n.Fun.(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj := v.scope.Lookup(fun.Name)
+	if obj == nil {
+		v.c.call(n)
+		if verb, ok := mutatingBuiltins[fun.Name]; ok && len(n.Args) > 0 {
+			v.write(n.Args[0], verb)
+		}
+		return
+	}
+	fn := signature(obj)
+	if fn == nil || fn.Params == nil {
+		return
+	}
+
+	var params []*ast.Field
+	for _, f := range fn.Params.List {
+		params = append(params, f)
+		for i := 1; i < len(f.Names); i++ {
+			params = append(params, f)
+		}
+	}
+	for i, arg := range n.Args {
+		if len(params) == 0 {
+			break
+		}
+		p := params[len(params)-1]
+		if i < len(params) {
+			p = params[i]
+		}
+		t, ro := v.view(arg)
+		if ro != nil && v.c.reference(t) && !v.c.readOnly(p.Type) {
+			v.c.constError(arg, "cannot pass %s to mutable parameter of %s (%s is const)",
+				v.c.source(arg), fun.Name, v.c.source(ro))
+		}
+	}
+}
+
+// write reports a write to x, described by verb, through a read-only
+// view. It returns whether it reported one.
+func (v *constVisitor) write(x ast.Expr, verb string) bool {
+	x = unparen(x)
+	if id, ok := x.(*ast.Ident); ok {
+		if obj := v.scope.Lookup(id.Name); obj != nil && v.c.binding(obj) {
+			v.c.constError(x, "cannot %s const %s", verb, id.Name)
+			return true
+		}
+		return false
+	}
+
+	_, ro := v.view(x)
+	switch {
+	case ro == nil:
+		return false
+	case ro == x:
+		v.c.constError(x, "cannot %s const %s", verb, v.c.source(x))
+	default:
+		v.c.constError(x, "cannot %s %s (%s is const)", verb, v.c.source(x), v.c.source(ro))
+	}
+	return true
+}
+
+// store reports storing the read-only reference value in target, of the
+// mutable type t.
+func (v *constVisitor) store(value ast.Expr, t ast.Type, target ast.Expr) {
+	vt, ro := v.view(value)
+	if ro != nil && v.c.reference(vt) && !v.c.readOnly(t) {
+		v.c.constError(value, "cannot assign %s to mutable %s (%s is const)",
+			v.c.source(value), v.c.source(target), v.c.source(ro))
+	}
+}
+
+// alias returns the object of a variable defined from value.
+func (v *constVisitor) alias(value ast.Expr) *ast.Object {
+	if value == nil {
+		return &ast.Object{Kind: ast.Var}
+	}
+	return v.derive(v.view(value))
+}
+
+// derive returns the object of a variable of type t holding a value read
+// through the read-only view ro. A copy of a value type is mutable.
+func (v *constVisitor) derive(t ast.Type, ro ast.Expr) *ast.Object {
+	if !v.c.reference(t) {
+		return &ast.Object{Kind: ast.Var, Type: v.c.unconst(t)}
+	}
+	return &ast.Object{Kind: ast.Var, Type: t, Data: ro}
+}
+
+// view returns the type of x, if known, and the outermost expression that
+// makes x read-only, or nil if x is writable.
+func (v *constVisitor) view(x ast.Expr) (ast.Type, ast.Expr) {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return v.view(x.X)
+	case *ast.Ident:
+		obj := v.scope.Lookup(x.Name)
+		if obj == nil || obj.Kind == ast.Fun || obj.Kind == ast.Typ {
+			return nil, nil
+		}
+		ro := viewOf(obj)
+		if ro == nil && (obj.Kind == ast.Con || v.c.readOnly(obj.Type)) {
+			ro = x
+		}
+		return obj.Type, ro
+	case *ast.SelectorExpr:
+		t, ro := v.view(x.X)
+		return v.c.step(v.c.field(t, x.Sel.Name), ro, x)
+	case *ast.IndexExpr:
+		t, ro := v.view(x.X)
+		return v.c.step(v.c.elem(t), ro, x)
+	case *ast.SliceExpr:
+		return v.view(x.X)
+	case *ast.StarExpr:
+		return v.indirect(x.X, x)
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case ast.MUL:
+			return v.indirect(x.X, x)
+		case ast.AND:
+			t, ro := v.view(x.X)
+			if t == nil {
+				return nil, ro
+			}
+			return &ast.PointerType{Base: t}, ro
+		}
+	case *ast.CompositeLit:
+		return x.Type, nil
+	case *ast.SliceLit:
+		return &ast.PointerType{Base: &ast.SliceType{Elem: x.Type}}, nil
+	case *ast.MapLit:
+		return &ast.PointerType{Base: &ast.MapType{Key: x.Key, Value: x.Value}}, nil
+	case *ast.SetLit:
+		return &ast.PointerType{Base: &ast.MapType{Key: x.Elem}}, nil
+	case *ast.ChanLit:
+		return &ast.PointerType{Base: &ast.ChanType{Dir: x.Dir, Value: x.Type}}, nil
+	case *ast.CallExpr:
+		if fun, ok := x.Fun.(*ast.Ident); ok {
+			if fn := signature(v.scope.Lookup(fun.Name)); fn != nil &&
+				fn.Results != nil && len(fn.Results.List) == 1 && len(fn.Results.List[0].Names) <= 1 {
+				return fn.Results.List[0].Type, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// indirect returns the type of x, the indirection of pointer, and the
+// expression making it read-only.
+func (v *constVisitor) indirect(pointer, x ast.Expr) (ast.Type, ast.Expr) {
+	t, ro := v.view(pointer)
+	var base ast.Type
+	if p, ok := v.c.underlying(t).(*ast.PointerType); ok {
+		base = p.Base
+	}
+	return v.c.step(base, ro, x)
+}
+
+// step returns the type t of x, reached from a value read through ro, and
+// the expression making x read-only.
+func (c *checker) step(t ast.Type, ro ast.Expr, x ast.Expr) (ast.Type, ast.Expr) {
+	if ro == nil && c.readOnly(t) {
+		ro = x
+	}
+	return t, ro
+}
+
+// readOnly reports whether t is a const type.
+func (c *checker) readOnly(t ast.Type) bool {
+	for i := 0; t != nil && i < len(c.types)+1; i++ {
+		switch u := t.(type) {
+		case *ast.ConstType:
+			return true
+		case *ast.ParenType:
+			t = u.X
+		case *ast.Ident:
+			t = c.types[u.Name]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// unconst returns t without a const qualifier.
+func (c *checker) unconst(t ast.Type) ast.Type {
+	for {
+		switch u := t.(type) {
+		case *ast.ConstType:
+			t = u.Type
+		case *ast.ParenType:
+			t = u.X
+		default:
+			return t
+		}
+	}
+}
+
+// underlying returns the type literal t denotes, without const qualifiers
+// and names of types declared in the file, or nil if it is unknown.
+func (c *checker) underlying(t ast.Type) ast.Type {
+	for i := 0; t != nil && i < len(c.types)+1; i++ {
+		t = c.unconst(t)
+		id, ok := t.(*ast.Ident)
+		if !ok {
+			return t
+		}
+		t = c.types[id.Name]
+	}
+	return nil
+}
+
+// container returns the underlying type of t, or of its base type if t
+// is a pointer: indexing, ranging and selecting through a pointer are
+// implicit.
+func (c *checker) container(t ast.Type) ast.Type {
+	u := c.underlying(t)
+	if p, ok := u.(*ast.PointerType); ok {
+		return c.underlying(p.Base)
+	}
+	return u
+}
+
+// reference reports whether values of type t share their contents when
+// copied.
+func (c *checker) reference(t ast.Type) bool {
+	switch c.underlying(t).(type) {
+	case *ast.PointerType, *ast.SliceType, *ast.MapType, *ast.ChanType:
+		return true
+	}
+	return false
+}
+
+// elem returns the element type of the indexable type t, or nil.
+func (c *checker) elem(t ast.Type) ast.Type {
+	switch u := c.container(t).(type) {
+	case *ast.SliceType:
+		return u.Elem
+	case *ast.ArrayType:
+		return u.Elem
+	case *ast.MapType:
+		return u.Value
+	}
+	return nil
+}
+
+// field returns the type of the field name of the struct type t, or nil.
+func (c *checker) field(t ast.Type, name string) ast.Type {
+	s, ok := c.container(t).(*ast.StructType)
+	if !ok || s.Fields == nil {
+		return nil
+	}
+	for _, f := range s.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == name {
+				return f.Type
+			}
+		}
+	}
+	return nil
+}
+
+// unparen returns x without enclosing parentheses.
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
+
+// constError adds an error diagnostic for a write through a read-only
+// view at n.
+func (c *checker) constError(n ast.Node, format string, args ...interface{}) {
+	d := diag.Errorf(n.Pos(), diag.ConstMutation, format, args...)
+	d.End = n.End()
+	c.diags.Add(d)
+}
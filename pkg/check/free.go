@@ -0,0 +1,698 @@
+package check
+
+import (
+	"fmt"
+
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// Frees
+//
+// The builtin free releases the memory of a slice, map or channel. The
+// local variables and parameters of each function are followed through
+// its statements, and these errors are reported:
+//
+//   - a double free: freeing a variable already freed on every path to
+//     the call, including by a deferred free or in a previous iteration
+//     of a loop
+//   - a use after free: reading a variable freed on every path to the use
+//   - a free of an escaping value: freeing a variable that was returned,
+//     sent on a channel or handed to a go statement, or returning or
+//     sending one that a deferred free releases when the function returns
+//
+// A branch joins the paths after it only if it can complete: a return,
+// continue, goto, panic or break ends it. A break joins the paths after
+// the loop, switch or select it leaves. Assigning a variable gives it a
+// new value that is not freed. A free in a deferred function literal,
+// such as one that calls recover, is a deferred free. Function literals
+// are analysed as functions of their own; the variables they capture are
+// only checked for uses when the literal is created.
+
+// variable is a local variable or parameter.
+type variable struct {
+	name string
+}
+
+// freeState is what is known of a variable at a point of a function.
+type freeState struct {
+	freed    ast.Position // Free on every path, if valid
+	carried  bool         // The free is from the previous loop iteration
+	deferred ast.Position // Deferred free on every path, if valid
+	escape   ast.Position // Escape on some path, if valid
+	how      string       // How it escapes: "return", "send" or "go statement"
+	reported bool         // A use after the free has been reported
+}
+
+// flow maps the variables in scope to their state.
+type flow map[*variable]freeState
+
+func (fl flow) copy() flow {
+	out := make(flow, len(fl))
+	for v, st := range fl {
+		out[v] = st
+	}
+	return out
+}
+
+// merge joins the states of the paths reaching the same point: a free
+// holds if it holds on every path, an escape if it happens on any.
+func merge(paths []flow) flow {
+	out := make(flow)
+	for v, st := range paths[0] {
+		all := true
+		for _, p := range paths[1:] {
+			other, ok := p[v]
+			if !ok {
+				all = false
+				break
+			}
+			if !other.freed.IsValid() {
+				st.freed, st.carried = ast.Position{}, false
+			}
+			st.carried = st.carried || other.carried
+			st.reported = st.reported || other.reported
+			if !other.deferred.IsValid() {
+				st.deferred = ast.Position{}
+			}
+			if !st.escape.IsValid() {
+				st.escape, st.how = other.escape, other.how
+			}
+		}
+		if all {
+			out[v] = st
+		}
+	}
+	return out
+}
+
+// freeFlow follows the frees of one function. The objects of its scopes
+// have the variables as their Data.
+type freeFlow struct {
+	c       *checker
+	scope   *ast.Scope
+	state   flow
+	ended   bool               // The current path has ended
+	closure func(*ast.FuncLit) // Queues a function literal for analysis
+
+	targets []*breakTarget // Enclosing statements a break may leave
+	label   string         // Label of the statement being followed
+}
+
+// breakTarget is a loop, switch or select that a break may leave.
+type breakTarget struct {
+	label  string
+	breaks []flow // States at the breaks out of the statement
+}
+
+// frees reports misplaced calls of the builtin free in file.
+func (c *checker) frees(file *ast.File) {
+	for _, d := range file.Decls {
+		if shadowsFree(d) {
+			return
+		}
+	}
+
+	c.freeSeen = make(map[string]bool)
+	var queue []*ast.FuncLit
+	queued := make(map[*ast.FuncLit]bool)
+	enqueue := func(lit *ast.FuncLit) {
+		if !queued[lit] {
+			queued[lit] = true
+			queue = append(queue, lit)
+		}
+	}
+
+	for _, d := range file.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Body != nil {
+			c.freeFunc(fn.Recv, fn.Type, fn.Body, enqueue)
+		}
+	}
+	for len(queue) > 0 {
+		lit := queue[0]
+		queue = queue[1:]
+		if lit.Body != nil {
+			c.freeFunc(nil, lit.Type, lit.Body, enqueue)
+		}
+	}
+}
+
+// shadowsFree reports whether the top-level declaration d declares the
+// name free.
+func shadowsFree(d ast.Decl) bool {
+	var names []*ast.Ident
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name)
+		}
+	case *ast.VarDecl:
+		for _, spec := range d.Specs {
+			names = append(names, spec.Names...)
+		}
+	case *ast.ConstDecl:
+		for _, spec := range d.Specs {
+			names = append(names, spec.Names...)
+		}
+	case *ast.TypeDecl:
+		for _, spec := range d.Specs {
+			names = append(names, spec.Name)
+		}
+	}
+	for _, name := range names {
+		if name != nil && name.Name == "free" {
+			return true
+		}
+	}
+	return false
+}
+
+// freeFunc follows the frees of a function body.
+func (c *checker) freeFunc(recv *ast.FieldList, t *ast.FuncType, body *ast.BlockStmt, closure func(*ast.FuncLit)) {
+	f := &freeFlow{c: c, state: make(flow), closure: closure}
+	f.open()
+	f.params(recv)
+	if t != nil {
+		f.params(t.Params)
+		f.params(t.Results)
+	}
+	f.stmts(body.List)
+}
+
+func (f *freeFlow) open() {
+	f.scope = ast.NewScope(f.scope)
+}
+
+func (f *freeFlow) close() {
+	for _, obj := range f.scope.Objects {
+		delete(f.state, obj.Data.(*variable))
+	}
+	f.scope = f.scope.Outer
+}
+
+// declare adds a variable to the current scope.
+func (f *freeFlow) declare(id *ast.Ident) {
+	if id == nil || id.Name == "_" {
+		return
+	}
+	v := &variable{name: id.Name}
+	f.scope.Insert(&ast.Object{Kind: ast.Var, Name: id.Name, Data: v})
+	f.state[v] = freeState{}
+}
+
+func (f *freeFlow) params(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			f.declare(name)
+		}
+	}
+}
+
+// tracked returns the variable x names, or nil.
+func (f *freeFlow) tracked(x ast.Expr) *variable {
+	id, ok := unparen(x).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := f.scope.Lookup(id.Name)
+	if obj == nil {
+		return nil
+	}
+	v := obj.Data.(*variable)
+	if _, ok := f.state[v]; !ok {
+		return nil
+	}
+	return v
+}
+
+func (f *freeFlow) stmts(list []ast.Stmt) {
+	for _, s := range list {
+		f.stmt(s)
+	}
+}
+
+func (f *freeFlow) stmt(s ast.Stmt) {
+	label := f.label
+	f.label = ""
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		f.open()
+		f.stmts(s.List)
+		f.close()
+	case *ast.ExprStmt:
+		f.expr(s.X)
+	case *ast.DeclStmt:
+		if d, ok := s.Decl.(*ast.VarDecl); ok {
+			for _, spec := range d.Specs {
+				f.exprs(spec.Values)
+				for _, name := range spec.Names {
+					f.declare(name)
+				}
+			}
+		}
+	case *ast.AssignStmt:
+		f.assign(s)
+	case *ast.IncDecStmt:
+		f.expr(s.X)
+	case *ast.SendStmt:
+		f.expr(s.Chan)
+		f.expr(s.Value)
+		f.escapes(s.Value, s.Pos(), "send")
+	case *ast.ReturnStmt:
+		f.exprs(s.Results)
+		for _, r := range s.Results {
+			f.escapes(r, r.Pos(), "return")
+		}
+		f.ended = true
+	case *ast.BranchStmt:
+		if t := f.target(s); t != nil {
+			t.breaks = append(t.breaks, f.state.copy())
+		}
+		f.ended = true
+	case *ast.GoStmt:
+		f.expr(s.Call)
+		ast.Inspect(s.Call, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				f.escapes(id, s.Pos(), "go statement")
+			}
+			return true
+		})
+	case *ast.DeferStmt:
+		f.deferStmt(s)
+	case *ast.LabeledStmt:
+		f.label = s.Label.Name
+		f.stmt(s.Stmt)
+	case *ast.IfStmt:
+		f.open()
+		if s.Init != nil {
+			f.stmt(s.Init)
+		}
+		f.expr(s.Cond)
+		paths := []func(){func() { f.stmt(s.Body) }}
+		if s.Else != nil {
+			paths = append(paths, func() { f.stmt(s.Else) })
+		} else {
+			paths = append(paths, func() {})
+		}
+		f.branch(paths, nil)
+		f.close()
+	case *ast.ForStmt:
+		f.open()
+		if s.Init != nil {
+			f.stmt(s.Init)
+		}
+		f.expr(s.Cond)
+		f.loop(label, s.Cond == nil, func() {
+			f.stmt(s.Body)
+			if s.Post != nil {
+				f.stmt(s.Post)
+			}
+		})
+		f.close()
+	case *ast.RangeStmt:
+		f.expr(s.X)
+		f.loop(label, false, func() {
+			f.open()
+			if s.Tok == ast.DEFINE {
+				f.declareExpr(s.Key)
+				f.declareExpr(s.Value)
+			} else {
+				f.rebind(s.Key)
+				f.rebind(s.Value)
+			}
+			f.stmt(s.Body)
+			f.close()
+		})
+	case *ast.SwitchStmt:
+		f.open()
+		if s.Init != nil {
+			f.stmt(s.Init)
+		}
+		f.expr(s.Tag)
+		f.clauses(label, s.Body, true)
+		f.close()
+	case *ast.TypeSwitchStmt:
+		f.open()
+		if s.Init != nil {
+			f.stmt(s.Init)
+		}
+		if s.Assign != nil {
+			f.stmt(s.Assign)
+		}
+		f.clauses(label, s.Body, true)
+		f.close()
+	case *ast.SelectStmt:
+		f.clauses(label, s.Body, false)
+	}
+}
+
+// push enters a statement that a break may leave.
+func (f *freeFlow) push(label string) *breakTarget {
+	t := &breakTarget{label: label}
+	f.targets = append(f.targets, t)
+	return t
+}
+
+func (f *freeFlow) pop() {
+	f.targets = f.targets[:len(f.targets)-1]
+}
+
+// target returns the statement the break s leaves, or nil if s is not a
+// break or its target is unknown.
+func (f *freeFlow) target(s *ast.BranchStmt) *breakTarget {
+	if s.Tok != ast.BREAK {
+		return nil
+	}
+	for i := len(f.targets) - 1; i >= 0; i-- {
+		if t := f.targets[i]; s.Label == nil || t.label == s.Label.Name {
+			return t
+		}
+	}
+	return nil
+}
+
+// assign follows an assignment: the right-hand side is read, then the
+// variables on the left get new values.
+func (f *freeFlow) assign(s *ast.AssignStmt) {
+	f.exprs(s.Rhs)
+	for _, lhs := range s.Lhs {
+		id, ok := lhs.(*ast.Ident)
+		switch {
+		case !ok:
+			f.expr(lhs)
+		case s.Tok == ast.DEFINE && f.scope.Objects[id.Name] == nil:
+			f.declare(id)
+		case s.Tok == ast.DEFINE || s.Tok == ast.ASSIGN:
+			f.rebind(id)
+		default:
+			f.expr(id)
+		}
+	}
+}
+
+// declareExpr declares x if it is a name.
+func (f *freeFlow) declareExpr(x ast.Expr) {
+	if id, ok := x.(*ast.Ident); ok {
+		f.declare(id)
+	}
+}
+
+// rebind records that x, if it is a variable, holds a new value.
+func (f *freeFlow) rebind(x ast.Expr) {
+	if x == nil {
+		return
+	}
+	if v := f.tracked(x); v != nil {
+		f.state[v] = freeState{}
+	} else {
+		f.expr(x)
+	}
+}
+
+// deferStmt follows a defer statement. The arguments of the call are read
+// now; the frees in it happen when the function returns.
+func (f *freeFlow) deferStmt(s *ast.DeferStmt) {
+	if s.Call == nil {
+		return
+	}
+	if arg := f.freeArg(s.Call); arg != nil {
+		if v := f.tracked(arg); v != nil {
+			f.deferFree(v, s.Pos())
+			return
+		}
+	}
+	if lit, ok := s.Call.Fun.(*ast.FuncLit); ok && lit.Body != nil {
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				if arg := f.freeArg(n); arg != nil {
+					if v := f.tracked(arg); v != nil {
+						f.deferFree(v, n.Pos())
+					}
+				}
+			}
+			return true
+		})
+	}
+	f.expr(s.Call)
+}
+
+// branch follows alternative paths from the current state and joins
+// those that complete, and those that break out of t if it is not nil.
+func (f *freeFlow) branch(paths []func(), t *breakTarget) {
+	entry := f.state
+	var ends []flow
+	for _, path := range paths {
+		f.state = entry.copy()
+		path()
+		if !f.ended {
+			ends = append(ends, f.state)
+		}
+		f.ended = false
+	}
+	if t != nil {
+		ends = append(ends, t.breaks...)
+	}
+	if len(ends) == 0 {
+		f.state = entry
+		f.ended = true
+		return
+	}
+	f.state = merge(ends)
+}
+
+// clauses follows the clauses of a switch or select body. Without a
+// default clause a switch may run none of them.
+func (f *freeFlow) clauses(label string, body *ast.BlockStmt, orNone bool) {
+	if body == nil {
+		return
+	}
+	t := f.push(label)
+	defer f.pop()
+	var paths []func()
+	for _, s := range body.List {
+		switch cl := s.(type) {
+		case *ast.CaseClause:
+			if cl.List == nil {
+				orNone = false
+			}
+			paths = append(paths, func() {
+				f.exprs(cl.List)
+				f.open()
+				f.stmts(cl.Body)
+				f.close()
+			})
+		case *ast.CommClause:
+			if cl.Comm == nil {
+				orNone = false
+			}
+			paths = append(paths, func() {
+				f.open()
+				if cl.Comm != nil {
+					f.stmt(cl.Comm)
+				}
+				f.stmts(cl.Body)
+				f.close()
+			})
+		}
+	}
+	if orNone {
+		paths = append(paths, func() {})
+	}
+	if len(paths) > 0 {
+		f.branch(paths, t)
+	}
+}
+
+// loop follows a loop body twice: the second pass starts from the state
+// at the end of the first, as the next iteration does. The state after
+// the loop joins the states at its breaks and, unless it runs forever,
+// the state before it, as the loop may not run at all.
+func (f *freeFlow) loop(label string, forever bool, body func()) {
+	t := f.push(label)
+	defer f.pop()
+	entry := f.state
+	f.state = entry.copy()
+	body()
+	if !f.ended {
+		next := f.state.copy()
+		for v, st := range next {
+			if st.freed.IsValid() && !entry[v].freed.IsValid() {
+				st.carried = true
+				next[v] = st
+			}
+		}
+		f.state = next
+		body()
+	}
+	f.ended = false
+	ends := t.breaks
+	for _, end := range ends {
+		for v, st := range end {
+			if st.carried && !entry[v].freed.IsValid() {
+				st.carried = false
+				end[v] = st
+			}
+		}
+	}
+	if !forever {
+		ends = append(ends, entry)
+	}
+	if len(ends) == 0 {
+		f.state = entry
+		f.ended = true
+		return
+	}
+	f.state = merge(ends)
+}
+
+func (f *freeFlow) exprs(list []ast.Expr) {
+	for _, x := range list {
+		f.expr(x)
+	}
+}
+
+// expr follows the reads and frees in x.
+func (f *freeFlow) expr(x ast.Expr) {
+	if x == nil {
+		return
+	}
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					f.use(id)
+				}
+				return true
+			})
+			f.closure(n)
+			return false
+		case *ast.CallExpr:
+			if arg := f.freeArg(n); arg != nil {
+				if v := f.tracked(arg); v != nil {
+					f.free(v, n.Pos())
+					return false
+				}
+			}
+			if id, ok := n.Fun.(*ast.Ident); ok && id.Name == "panic" && f.scope.Lookup("panic") == nil {
+				f.exprs(n.Args)
+				f.ended = true
+				return false
+			}
+		case *ast.SelectorExpr:
+			f.expr(n.X)
+			return false
+		case *ast.KeyValueExpr:
+			if _, ok := n.Key.(*ast.Ident); !ok {
+				f.expr(n.Key)
+			}
+			f.expr(n.Value)
+			return false
+		case *ast.Ident:
+			f.use(n)
+		}
+		return true
+	})
+}
+
+// freeArg returns the argument of a call of the builtin free, or nil if
+// call calls something else, such as a local variable named free.
+func (f *freeFlow) freeArg(call *ast.CallExpr) ast.Expr {
+	if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "free" && len(call.Args) == 1 && f.scope.Lookup("free") == nil {
+		return call.Args[0]
+	}
+	return nil
+}
+
+// use reports a read of a freed variable.
+func (f *freeFlow) use(id *ast.Ident) {
+	v := f.tracked(id)
+	if v == nil {
+		return
+	}
+	st := f.state[v]
+	if !st.freed.IsValid() || st.reported {
+		return
+	}
+	f.c.freeError(id, "use of %s after free on line %d", v.name, st.freed.Line)
+	st.reported = true
+	f.state[v] = st
+}
+
+// free follows a call free(v) at pos.
+func (f *freeFlow) free(v *variable, pos ast.Position) {
+	st := f.state[v]
+	switch {
+	case st.freed.IsValid() && st.carried:
+		f.c.freeErrorAt(pos, "double free of %s: freed on line %d in the previous iteration", v.name, st.freed.Line)
+	case st.freed.IsValid():
+		f.c.freeErrorAt(pos, "double free of %s: already freed on line %d", v.name, st.freed.Line)
+	case st.deferred.IsValid():
+		f.c.freeErrorAt(pos, "double free of %s: also freed by defer on line %d", v.name, st.deferred.Line)
+	case st.escape.IsValid():
+		f.c.freeErrorAt(pos, "free of %s, which escapes by %s on line %d", v.name, st.how, st.escape.Line)
+	}
+	if !st.freed.IsValid() || st.carried {
+		st.freed, st.carried = pos, false
+	}
+	st.reported = false
+	f.state[v] = st
+}
+
+// deferFree follows a deferred free of v at pos.
+func (f *freeFlow) deferFree(v *variable, pos ast.Position) {
+	st := f.state[v]
+	switch {
+	case st.freed.IsValid():
+		f.c.freeErrorAt(pos, "double free of %s: already freed on line %d", v.name, st.freed.Line)
+	case st.deferred.IsValid():
+		f.c.freeErrorAt(pos, "double free of %s: also freed by defer on line %d", v.name, st.deferred.Line)
+	case st.escape.IsValid():
+		f.c.freeErrorAt(pos, "free of %s, which escapes by %s on line %d", v.name, st.how, st.escape.Line)
+	}
+	st.deferred = pos
+	f.state[v] = st
+}
+
+// escapes records that x, if it is a variable, escapes at pos.
+func (f *freeFlow) escapes(x ast.Expr, pos ast.Position, how string) {
+	v := f.tracked(x)
+	if v == nil {
+		return
+	}
+	st := f.state[v]
+	if st.deferred.IsValid() {
+		f.c.freeErrorAt(pos, "%s escapes by %s but is freed by defer on line %d", v.name, how, st.deferred.Line)
+	}
+	if !st.escape.IsValid() {
+		st.escape, st.how = pos, how
+		f.state[v] = st
+	}
+}
+
+// freeError adds an error diagnostic for the range of n, once.
+func (c *checker) freeError(n ast.Node, format string, args ...interface{}) {
+	d := diag.Errorf(n.Pos(), diag.FreeMisuse, format, args...)
+	d.End = n.End()
+	c.addFree(d)
+}
+
+// freeErrorAt adds an error diagnostic at pos, once.
+func (c *checker) freeErrorAt(pos ast.Position, format string, args ...interface{}) {
+	c.addFree(diag.Errorf(pos, diag.FreeMisuse, format, args...))
+}
+
+// addFree adds d unless a second pass over a loop body found it already.
+func (c *checker) addFree(d *diag.Diagnostic) {
+	key := fmt.Sprintf("%d:%s", d.Pos.Offset, d.Message)
+	if !c.freeSeen[key] {
+		c.freeSeen[key] = true
+		c.diags.Add(d)
+	}
+}
@@ -0,0 +1,123 @@
+// Package diag defines structured diagnostics reported by the Moxie
+// front-end and analysis passes.
+//
+// A Diagnostic carries a source range, a severity, a stable error code and
+// optional fix suggestions. Diagnostics from every stage are collected in a
+// List, which can be sorted, deduplicated and rendered either for a terminal
+// (optionally colorized) or as JSON for editors and other tools.
+package diag
+
+import (
+	"fmt"
+
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// Severity classifies how serious a diagnostic is.
+type Severity int
+
+const (
+	Error   Severity = iota // Compilation cannot proceed
+	Warning                 // Suspicious but legal code
+	Info                    // Informational note
+	Hint                    // Style or improvement hint
+)
+
+var severities = [...]string{
+	Error:   "error",
+	Warning: "warning",
+	Info:    "info",
+	Hint:    "hint",
+}
+
+// String returns the lower-case name of the severity.
+func (s Severity) String() string {
+	if 0 <= s && int(s) < len(severities) {
+		return severities[s]
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severities {
+		if name == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("diag: unknown severity %q", text)
+}
+
+// Code is a stable identifier for a class of diagnostics, e.g. "MX0001".
+// Codes never change meaning once published, so tools may match on them.
+type Code string
+
+// Front-end diagnostic codes.
+const (
+	SyntaxError   Code = "MX0001" // Lexer or parser error
+	BuildError    Code = "MX0002" // Parse tree could not be converted to an AST
+	Unsupported   Code = "MX0003" // Go construct without Moxie meaning
+	ConstMutation Code = "MX0004" // Write through a const declaration or type
+	FreeMisuse    Code = "MX0005" // Double free, use after free or free of an escaping value
+)
+
+// Suggestion is a possible fix for a diagnostic.
+type Suggestion struct {
+	Message     string // Human-readable description of the fix
+	Replacement string // Source text replacing the diagnostic range (may be empty)
+}
+
+// Diagnostic is a single message about a range of Moxie source.
+type Diagnostic struct {
+	Pos         ast.Position // Start of the offending range
+	End         ast.Position // End of the offending range (may be invalid)
+	Severity    Severity     // Severity
+	Code        Code         // Diagnostic code (may be empty)
+	Message     string       // Message text
+	Suggestions []Suggestion // Possible fixes
+}
+
+// Errorf creates an error diagnostic at pos.
+func Errorf(pos ast.Position, code Code, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Pos:      pos,
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Warningf creates a warning diagnostic at pos.
+func Warningf(pos ast.Position, code Code, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Pos:      pos,
+		Severity: Warning,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Suggest appends a fix suggestion and returns d to allow chaining.
+func (d *Diagnostic) Suggest(message, replacement string) *Diagnostic {
+	d.Suggestions = append(d.Suggestions, Suggestion{Message: message, Replacement: replacement})
+	return d
+}
+
+// Error implements the error interface.
+// The format is "file:line:col: severity[code]: message".
+func (d *Diagnostic) Error() string {
+	s := d.Severity.String()
+	if d.Code != "" {
+		s += "[" + string(d.Code) + "]"
+	}
+	if d.Pos.IsValid() {
+		return d.Pos.String() + ": " + s + ": " + d.Message
+	}
+	return s + ": " + d.Message
+}
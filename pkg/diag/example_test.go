@@ -0,0 +1,141 @@
+package diag_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mleku/moxie/pkg/ast"
+	"github.com/mleku/moxie/pkg/diag"
+)
+
+// Example demonstrates collecting and printing diagnostics.
+func Example_print() {
+	var list diag.List
+	list.Add(diag.Warningf(ast.Position{Filename: "main.mx", Line: 7, Column: 2}, "", "unused variable x"))
+	list.Add(diag.Errorf(ast.Position{Filename: "main.mx", Line: 3, Column: 9}, diag.SyntaxError, "missing '}'").
+		Suggest("close the block", "}"))
+
+	list.Sort()
+	diag.Fprint(os.Stdout, list, diag.PrintOptions{})
+
+	// Output:
+	// main.mx:3:9: error[MX0001]: missing '}'
+	// 	suggestion: close the block: }
+	// main.mx:7:2: warning: unused variable x
+}
+
+func TestRemoveMultiples(t *testing.T) {
+	pos := ast.Position{Filename: "a.mx", Line: 1, Column: 1}
+	var list diag.List
+	list.Add(diag.Errorf(pos, diag.SyntaxError, "bad"))
+	list.Add(diag.Errorf(ast.Position{Filename: "a.mx", Line: 2, Column: 1}, diag.SyntaxError, "bad"))
+	list.Add(diag.Errorf(pos, diag.SyntaxError, "bad"))
+	list.Add(diag.Errorf(pos, diag.BuildError, "bad"))
+	list.Add(nil)
+
+	list.RemoveMultiples()
+
+	if len(list) != 3 {
+		t.Fatalf("expected 3 diagnostics after dedup, got %d: %v", len(list), list)
+	}
+	if list[0].Pos.Line != 1 || list[2].Pos.Line != 2 {
+		t.Errorf("diagnostics not in source order: %v", list)
+	}
+}
+
+func TestErr(t *testing.T) {
+	var list diag.List
+	if list.Err() != nil {
+		t.Error("empty list should not be an error")
+	}
+
+	list.Add(diag.Warningf(ast.Position{Line: 1}, "", "just a warning"))
+	if list.Err() != nil {
+		t.Error("warnings alone should not be an error")
+	}
+
+	list.Add(diag.Errorf(ast.Position{Line: 2, Column: 4}, diag.SyntaxError, "broken"))
+	err := list.Err()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := err.Error(), "<input>:2:4: error[MX0001]: broken"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFprintColor(t *testing.T) {
+	var list diag.List
+	list.Add(diag.Errorf(ast.Position{Filename: "a.mx", Line: 1, Column: 1}, diag.SyntaxError, "bad"))
+
+	var buf bytes.Buffer
+	if err := diag.Fprint(&buf, list, diag.PrintOptions{Color: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "\x1b[31m") || !strings.Contains(out, "bad") {
+		t.Errorf("expected red error label, got %q", out)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var list diag.List
+	list.Add(diag.Errorf(ast.Position{Filename: "a.mx", Offset: 10, Line: 2, Column: 3}, diag.SyntaxError, "bad").
+		Suggest("remove it", ""))
+
+	var buf bytes.Buffer
+	if err := diag.WriteJSON(&buf, list); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []struct {
+		Pos struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"pos"`
+		End         *json.RawMessage `json:"end"`
+		Severity    diag.Severity    `json:"severity"`
+		Code        string           `json:"code"`
+		Message     string           `json:"message"`
+		Suggestions []struct {
+			Message string `json:"message"`
+		} `json:"suggestions"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(decoded))
+	}
+	d := decoded[0]
+	if d.Pos.Filename != "a.mx" || d.Pos.Line != 2 || d.Pos.Column != 3 {
+		t.Errorf("unexpected position %+v", d.Pos)
+	}
+	if d.End != nil {
+		t.Errorf("invalid end position should be omitted, got %s", *d.End)
+	}
+	if d.Severity != diag.Error || d.Code != "MX0001" || d.Message != "bad" {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if len(d.Suggestions) != 1 || d.Suggestions[0].Message != "remove it" {
+		t.Errorf("unexpected suggestions %+v", d.Suggestions)
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	for _, s := range []diag.Severity{diag.Error, diag.Warning, diag.Info, diag.Hint} {
+		text, _ := s.MarshalText()
+		var back diag.Severity
+		if err := back.UnmarshalText(text); err != nil || back != s {
+			t.Errorf("round trip of %v failed: got %v, %v", s, back, err)
+		}
+	}
+	var s diag.Severity
+	if err := s.UnmarshalText([]byte("fatal")); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
@@ -0,0 +1,119 @@
+package diag
+
+import (
+	"sort"
+	"strconv"
+)
+
+// List is a collection of diagnostics.
+// The zero value is an empty list ready to use.
+type List []*Diagnostic
+
+// Add appends a diagnostic to the list. Nil diagnostics are ignored.
+func (l *List) Add(d *Diagnostic) {
+	if d != nil {
+		*l = append(*l, d)
+	}
+}
+
+// Append appends all diagnostics from other to the list.
+func (l *List) Append(other List) {
+	for _, d := range other {
+		l.Add(d)
+	}
+}
+
+// Reset empties the list.
+func (l *List) Reset() { *l = (*l)[:0] }
+
+// Len implements sort.Interface.
+func (l List) Len() int { return len(l) }
+
+// Swap implements sort.Interface.
+func (l List) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// Less implements sort.Interface. Diagnostics are ordered by filename,
+// line, column, severity, code and finally message.
+func (l List) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Pos.Filename != b.Pos.Filename {
+		return a.Pos.Filename < b.Pos.Filename
+	}
+	if a.Pos.Line != b.Pos.Line {
+		return a.Pos.Line < b.Pos.Line
+	}
+	if a.Pos.Column != b.Pos.Column {
+		return a.Pos.Column < b.Pos.Column
+	}
+	if a.Severity != b.Severity {
+		return a.Severity < b.Severity
+	}
+	if a.Code != b.Code {
+		return a.Code < b.Code
+	}
+	return a.Message < b.Message
+}
+
+// Sort sorts the list in source order.
+func (l List) Sort() {
+	sort.Stable(l)
+}
+
+// RemoveMultiples sorts the list and removes duplicate diagnostics, i.e.
+// diagnostics at the same position with the same code and message. Several
+// passes reporting the same problem therefore produce a single entry.
+func (l *List) RemoveMultiples() {
+	l.Sort()
+	var last *Diagnostic
+	i := 0
+	for _, d := range *l {
+		if last == nil || d.Pos != last.Pos || d.Code != last.Code || d.Message != last.Message {
+			last = d
+			(*l)[i] = d
+			i++
+		}
+	}
+	*l = (*l)[:i]
+}
+
+// HasErrors reports whether the list contains at least one diagnostic of
+// Error severity.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the diagnostics with severity at least as serious as min.
+func (l List) Filter(min Severity) List {
+	var out List
+	for _, d := range l {
+		if d.Severity <= min {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Error implements the error interface.
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return l[0].Error() + " (and " + strconv.Itoa(len(l)-1) + " more)"
+}
+
+// Err returns an error equivalent to this list if it contains any
+// error-severity diagnostics, and nil otherwise.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l.Filter(Error)
+}
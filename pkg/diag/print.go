@@ -0,0 +1,150 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ANSI escape sequences used for colorized output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+var severityColors = [...]string{
+	Error:   ansiRed,
+	Warning: ansiYellow,
+	Info:    ansiBlue,
+	Hint:    ansiCyan,
+}
+
+// PrintOptions controls the terminal rendering of diagnostics.
+type PrintOptions struct {
+	Color bool // Use ANSI colors for severities
+}
+
+// Fprint writes the diagnostics in l to w, one per line in the form
+// "file:line:col: severity[code]: message", followed by any suggestions
+// indented on their own lines.
+func Fprint(w io.Writer, l List, opts PrintOptions) error {
+	for _, d := range l {
+		if err := fprintOne(w, d, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fprintOne(w io.Writer, d *Diagnostic, opts PrintOptions) error {
+	label := d.Severity.String()
+	if d.Code != "" {
+		label += "[" + string(d.Code) + "]"
+	}
+	if opts.Color {
+		color := ""
+		if 0 <= d.Severity && int(d.Severity) < len(severityColors) {
+			color = severityColors[d.Severity]
+		}
+		label = ansiBold + color + label + ansiReset
+	}
+
+	var err error
+	if d.Pos.IsValid() {
+		pos := d.Pos.String()
+		if opts.Color {
+			pos = ansiBold + pos + ansiReset
+		}
+		_, err = fmt.Fprintf(w, "%s: %s: %s\n", pos, label, d.Message)
+	} else {
+		_, err = fmt.Fprintf(w, "%s: %s\n", label, d.Message)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, s := range d.Suggestions {
+		line := "\tsuggestion: " + s.Message
+		if s.Replacement != "" {
+			line += ": " + s.Replacement
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTerminal reports whether f refers to a character device, which is the
+// usual condition for enabling PrintOptions.Color.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// jsonPosition is the machine-readable form of ast.Position.
+type jsonPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// jsonSuggestion is the machine-readable form of Suggestion.
+type jsonSuggestion struct {
+	Message     string `json:"message"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// jsonDiagnostic is the machine-readable form of Diagnostic.
+type jsonDiagnostic struct {
+	Pos         jsonPosition     `json:"pos"`
+	End         *jsonPosition    `json:"end,omitempty"`
+	Severity    Severity         `json:"severity"`
+	Code        Code             `json:"code,omitempty"`
+	Message     string           `json:"message"`
+	Suggestions []jsonSuggestion `json:"suggestions,omitempty"`
+}
+
+// WriteJSON writes l to w as a JSON array. Positions are encoded as objects
+// with filename, offset, line and column fields; severities as their names.
+func WriteJSON(w io.Writer, l List) error {
+	out := make([]jsonDiagnostic, 0, len(l))
+	for _, d := range l {
+		jd := jsonDiagnostic{
+			Pos: jsonPosition{
+				Filename: d.Pos.Filename,
+				Offset:   d.Pos.Offset,
+				Line:     d.Pos.Line,
+				Column:   d.Pos.Column,
+			},
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+		}
+		if d.End.IsValid() {
+			jd.End = &jsonPosition{
+				Filename: d.End.Filename,
+				Offset:   d.End.Offset,
+				Line:     d.End.Line,
+				Column:   d.End.Column,
+			}
+		}
+		for _, s := range d.Suggestions {
+			jd.Suggestions = append(jd.Suggestions, jsonSuggestion(s))
+		}
+		out = append(out, jd)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,97 @@
+package printer
+
+import (
+	"bytes"
+	"text/tabwriter"
+)
+
+// The printer output marks alignment cells and verbatim text for align.
+const (
+	cellSep = '\v'             // Ends a cell aligned with the cells above and below
+	escape  = tabwriter.Escape // Brackets literal and comment text
+)
+
+// verbatim brackets text so align neither splits nor alters it.
+func verbatim(text string) string {
+	mark := string([]byte{escape})
+	return mark + text + mark
+}
+
+// align pads the cells of consecutive lines with the same indentation to
+// equal widths, as gofmt aligns struct fields, keyed elements and line
+// comments, and removes the escape marks.
+func align(out []byte) []byte {
+	lines := logicalLines(out)
+
+	var result bytes.Buffer
+	for i := 0; i < len(lines); {
+		indent := leadingTabs(lines[i])
+		if !hasCells(lines[i]) {
+			result.Write(stripEscapes(lines[i]))
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(lines) && hasCells(lines[j]) && leadingTabs(lines[j]) == indent {
+			j++
+		}
+		tw := tabwriter.NewWriter(&result, 0, 8, 1, ' ', tabwriter.StripEscape)
+		for _, line := range lines[i:j] {
+			// Escaping the indentation keeps it out of the cell layout.
+			tw.Write([]byte(verbatim(string(line[:indent]))))
+			tw.Write(line[indent:])
+		}
+		tw.Flush()
+		i = j
+	}
+	return result.Bytes()
+}
+
+// logicalLines splits out after each newline that is not part of verbatim
+// text, such as a multi-line raw string. Each line keeps its newline.
+func logicalLines(out []byte) [][]byte {
+	var lines [][]byte
+	start, inEscape := 0, false
+	for i, b := range out {
+		switch {
+		case b == escape:
+			inEscape = !inEscape
+		case b == '\n' && !inEscape:
+			lines = append(lines, out[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(out) {
+		lines = append(lines, out[start:])
+	}
+	return lines
+}
+
+// hasCells reports whether line has aligned cells outside verbatim text.
+func hasCells(line []byte) bool {
+	inEscape := false
+	for _, b := range line {
+		switch {
+		case b == escape:
+			inEscape = !inEscape
+		case b == cellSep && !inEscape:
+			return true
+		}
+	}
+	return false
+}
+
+// leadingTabs returns the number of indentation tabs of line.
+func leadingTabs(line []byte) int {
+	n := 0
+	for n < len(line) && line[n] == '\t' {
+		n++
+	}
+	return n
+}
+
+// stripEscapes removes the escape marks from a line without cells.
+func stripEscapes(line []byte) []byte {
+	return bytes.ReplaceAll(line, []byte{escape}, nil)
+}
@@ -0,0 +1,56 @@
+package printer_test
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mleku/moxie/pkg/antlr"
+	"github.com/mleku/moxie/pkg/check"
+	"github.com/mleku/moxie/pkg/printer"
+)
+
+// FuzzTransform checks that any file the parser accepts can be checked and
+// printed as Moxie source that parses again, and that Go output, when the
+// printer produces it, is valid Go.
+func FuzzTransform(f *testing.F) {
+	files, err := filepath.Glob("../../examples/*/*.x")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no examples found: %v", err)
+	}
+	golden, _ := filepath.Glob(filepath.Join("testdata", "golden", "*", "input.mx"))
+	for _, name := range append(files, golden...) {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		file, diags := antlr.ParseFile("fuzz.mx", src)
+		if len(diags) > 0 {
+			return
+		}
+		check.File(file)
+
+		var moxie bytes.Buffer
+		if err := printer.Fprint(&moxie, file); err != nil {
+			t.Fatalf("Moxie output: %v", err)
+		}
+		if _, diags := antlr.ParseFile("fuzz.mx", moxie.String()); len(diags) > 0 {
+			t.Fatalf("Moxie output does not parse:\n%s\n%v", moxie.String(), diags)
+		}
+
+		var out bytes.Buffer
+		if err := (&printer.Config{Mode: printer.Go}).Fprint(&out, file); err != nil {
+			return
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), "fuzz.go", out.Bytes(), parser.ParseComments); err != nil {
+			t.Fatalf("Go output does not parse:\n%s\n%v", out.String(), err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package printer_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mleku/moxie/pkg/antlr"
+	"github.com/mleku/moxie/pkg/check"
+	"github.com/mleku/moxie/pkg/printer"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestGolden transpiles each testdata/golden/<case>/input.mx and compares
+// the Go output with expected.go and the diagnostics with expected.diag.
+// Either file is absent if the case has no Go output or no diagnostics;
+// a printer error counts as a diagnostic. Run with -update to rewrite
+// them.
+func TestGolden(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "golden", "*"))
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no golden cases found: %v", err)
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join(dir, "input.mx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, diags := transpile(string(src))
+			golden(t, filepath.Join(dir, "expected.go"), out)
+			golden(t, filepath.Join(dir, "expected.diag"), diags)
+		})
+	}
+}
+
+// transpile parses and checks src and prints it as Go. It returns the Go
+// source, or nil if src has syntax errors or cannot be printed, and the
+// diagnostics, one per line.
+func transpile(src string) (out, diags []byte) {
+	var report bytes.Buffer
+	file, list := antlr.ParseFile("input.mx", src)
+	syntax := len(list) > 0
+	if !syntax {
+		list = check.File(file)
+	}
+	for _, d := range list {
+		report.WriteString(d.Error() + "\n")
+	}
+	if syntax {
+		return nil, report.Bytes()
+	}
+
+	var buf bytes.Buffer
+	if err := (&printer.Config{Mode: printer.Go}).Fprint(&buf, file); err != nil {
+		report.WriteString(err.Error() + "\n")
+		return nil, report.Bytes()
+	}
+	return buf.Bytes(), report.Bytes()
+}
+
+// golden compares got with the contents of the file name, which is absent
+// if got is empty, or rewrites the file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	if *update {
+		var err error
+		if len(got) == 0 {
+			err = os.Remove(name)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(name, got, 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+	}
+}
@@ -0,0 +1,491 @@
+package printer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// Go mode lowers some Moxie constructs to calls of standard packages:
+//
+//   - A string interpolation becomes a fmt.Sprintf call. Its operands are
+//     formatted with %v, except names declared as *[]byte, which are
+//     dereferenced and formatted with %s so that they print as text and
+//     not as a list of bytes.
+//   - A call of the builtin sort becomes a slices.Sort call, or a
+//     slices.SortFunc call if it has a comparator.
+//   - A call of the builtin assert becomes an if statement that panics
+//     with the Moxie source position, and the message formatted with
+//     fmt.Sprint, if the condition is false. A release build omits it.
+//
+// Names are resolved by scope within the file; a name of unknown type is
+// formatted with %v, a call of a declaration named sort or assert is not
+// lowered, and set[T] or &set[T]{...} under a declaration named set is
+// not the set type. A call of the builtins with invalid arguments is an error.
+// A package the lowerings use is imported if the file does not
+// import it, or imported again under a fresh name if a declaration hides
+// its name where it is used.
+
+// lowerPackages lists the packages the lowerings use, with the function
+// called from each.
+var lowerPackages = []struct{ path, fn string }{
+	{"fmt", "Sprintf"},
+	{"slices", "Sort"},
+}
+
+// lowering holds the names the Go lowerings of a file depend on.
+type lowering struct {
+	release bool                       // Assert statements are omitted
+	bytes   map[ast.Expr]bool          // Formatted operands declared as *[]byte
+	calls   map[*ast.CallExpr]string   // Calls of the builtins sort and assert
+	elems   map[*ast.CallExpr]ast.Type // Element types of the slices sorted with a comparator
+	notSets map[ast.Expr]bool          // set[T] and &set[T]{...} of a declaration named set
+	names   map[string]string          // Name each package is referred to by, by path
+	uses    map[string]bool            // Packages the lowerings use, by path
+	hidden  map[string]bool            // Packages whose name a declaration hides where used
+}
+
+// lower resolves the names the Go lowerings in f depend on and sets the
+// qualifiers of the packages they use. It returns the import declarations
+// of f with the imports they need added.
+func (p *printer) lower(f *ast.File) []*ast.ImportDecl {
+	l := &lowering{
+		release: p.release,
+		bytes:   make(map[ast.Expr]bool),
+		calls:   make(map[*ast.CallExpr]string),
+		elems:   make(map[*ast.CallExpr]ast.Type),
+		notSets: make(map[ast.Expr]bool),
+		names:   make(map[string]string),
+		uses:    make(map[string]bool),
+		hidden:  make(map[string]bool),
+	}
+	qualifiers := make(map[string]string)
+	for _, pkg := range lowerPackages {
+		q, ok := importName(f, pkg.path)
+		if !ok {
+			q = pkg.path + "."
+		}
+		qualifiers[pkg.path] = q
+		l.names[pkg.path] = strings.TrimSuffix(q, ".")
+		if q == "" {
+			l.names[pkg.path] = pkg.fn
+		}
+	}
+	l.resolve(f)
+	p.lowering = l
+
+	imports := f.Imports
+	for _, pkg := range lowerPackages {
+		if !l.uses[pkg.path] {
+			continue
+		}
+		_, ok := importName(f, pkg.path)
+		spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: ast.StringLit, Value: strconv.Quote(pkg.path)}}
+		if l.hidden[pkg.path] {
+			spec.Name = &ast.Ident{Name: freshName(f, pkg.path, imports)}
+			qualifiers[pkg.path], ok = spec.Name.Name+".", false
+		}
+		if !ok {
+			imports = addImport(imports, spec)
+		}
+	}
+	p.qualifiers = qualifiers
+	return imports
+}
+
+// qualifier returns the qualifier of package path in Go output.
+func (p *printer) qualifier(path string) string {
+	if q, ok := p.qualifiers[path]; ok {
+		return q
+	}
+	return path + "."
+}
+
+// builtin returns the name of the lowered builtin x calls, sort or
+// assert, or "". Outside a file every call of these names calls the
+// builtin.
+func (p *printer) builtin(x *ast.CallExpr) string {
+	if p.lowering != nil {
+		return p.lowering.calls[x]
+	}
+	return loweredBuiltin(x)
+}
+
+// setType reports whether x, an index set[T] or a set literal, has the
+// set type set[T], which Go spells map[T]struct{}, rather than a
+// declaration named set. Outside a file every set[T] is a set type.
+func (p *printer) setType(x ast.Expr) bool {
+	if ix, ok := x.(*ast.IndexExpr); ok {
+		if name, ok := ix.X.(*ast.Ident); !ok || name.Name != "set" {
+			return false
+		}
+	}
+	return p.lowering == nil || !p.lowering.notSets[x]
+}
+
+// loweredBuiltin returns sort or assert if x calls a function of that
+// name, or "".
+func loweredBuiltin(x *ast.CallExpr) string {
+	if id, ok := x.Fun.(*ast.Ident); ok && (id.Name == "sort" || id.Name == "assert") {
+		return id.Name
+	}
+	return ""
+}
+
+// builtinError returns why the call x of the builtin name cannot be
+// lowered, or "" if it can.
+func builtinError(x *ast.CallExpr, name string) string {
+	switch {
+	case x.Ellipsis.IsValid():
+		return "cannot use ... with builtin " + name
+	case name == "sort" && len(x.Args) != 1 && len(x.Args) != 2:
+		return fmt.Sprintf("builtin sort takes 1 or 2 arguments, not %d", len(x.Args))
+	case name == "assert" && len(x.Args) == 0:
+		return "builtin assert takes at least 1 argument"
+	}
+	return ""
+}
+
+// resolve walks f and records what its lowerings depend on.
+func (l *lowering) resolve(f *ast.File) {
+	v := &nameVisitor{l: l, scope: ast.NewScope(nil)}
+	for _, d := range f.Imports {
+		for _, s := range d.Specs {
+			if s.Path != nil && !l.lowers(s.Path.Value) {
+				v.scope.Insert(&ast.Object{Kind: ast.Pkg, Name: importedName(s)})
+			}
+		}
+	}
+	for _, d := range f.Decls {
+		v.decl(d)
+	}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			for _, spec := range d.Specs {
+				v.walkList(spec.Values)
+			}
+		case *ast.VarDecl:
+			for _, spec := range d.Specs {
+				v.walkList(spec.Values)
+			}
+		default:
+			ast.Walk(v, d)
+		}
+	}
+}
+
+// lowers reports whether the quoted import path is one of lowerPackages.
+func (l *lowering) lowers(path string) bool {
+	for _, pkg := range lowerPackages {
+		if path == strconv.Quote(pkg.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameVisitor follows the names declared in a scope. The objects have
+// the declared or evident types of the names.
+type nameVisitor struct {
+	l     *lowering
+	scope *ast.Scope
+}
+
+// open returns a visitor for a scope nested in v's.
+func (v *nameVisitor) open() *nameVisitor {
+	return &nameVisitor{l: v.l, scope: ast.NewScope(v.scope)}
+}
+
+func (v *nameVisitor) Visit(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		inner := v.open()
+		inner.fields(n.Recv)
+		inner.signature(n.Type)
+		return inner
+	case *ast.FuncLit:
+		inner := v.open()
+		inner.signature(n.Type)
+		return inner
+	case *ast.BlockStmt, *ast.IfStmt, *ast.ForStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt,
+		*ast.SelectStmt, *ast.CaseClause, *ast.CommClause:
+		return v.open()
+	case *ast.RangeStmt:
+		// The variables are declared after the range expression.
+		ast.Walk(v, n.X)
+		inner := v.open()
+		if n.Tok == ast.DEFINE {
+			inner.declare(n.Key, nil)
+			inner.declare(n.Value, nil)
+		} else {
+			v.walkList([]ast.Expr{n.Key, n.Value})
+		}
+		if n.Body != nil {
+			ast.Walk(inner, n.Body)
+		}
+		return nil
+	case *ast.DeclStmt:
+		// The names are declared after their values.
+		ast.Walk(v, n.Decl)
+		v.decl(n.Decl)
+		return nil
+	case *ast.AssignStmt:
+		if n.Tok == ast.DEFINE {
+			// The names are declared after the values.
+			v.walkList(n.Rhs)
+			types := make([]ast.Type, len(n.Lhs))
+			if len(n.Rhs) == len(n.Lhs) {
+				for i, x := range n.Rhs {
+					types[i] = v.typeOf(x)
+				}
+			}
+			for i, lhs := range n.Lhs {
+				v.declare(lhs, types[i])
+			}
+			v.walkList(n.Lhs)
+			return nil
+		}
+	case *ast.InterpolatedString:
+		if len(n.Exprs) > 0 {
+			v.use("fmt")
+		}
+		v.formatted(n.Exprs)
+	case *ast.IndexExpr:
+		if name, ok := n.X.(*ast.Ident); ok && name.Name == "set" && v.scope.Lookup("set") != nil {
+			v.l.notSets[n] = true
+		}
+	case *ast.SetLit:
+		if v.scope.Lookup("set") != nil {
+			v.l.notSets[n] = true
+		}
+	case *ast.CallExpr:
+		name := loweredBuiltin(n)
+		if name == "" || v.declared(name) {
+			break
+		}
+		v.l.calls[n] = name
+		switch {
+		case builtinError(n, name) != "":
+		case name == "assert" && v.l.release:
+			return nil // Omitted with its arguments
+		case name == "sort":
+			v.use("slices")
+			if len(n.Args) == 2 {
+				v.l.elems[n] = v.elemType(n)
+			}
+		case len(n.Args) > 1:
+			v.use("fmt")
+			v.formatted(n.Args[1:])
+		}
+	}
+	return v
+}
+
+// declared reports whether a call of name in the current scope calls a
+// declaration rather than a builtin. A package cannot be called, so an
+// import of the same name does not hide the builtin.
+func (v *nameVisitor) declared(name string) bool {
+	obj := v.scope.Lookup(name)
+	return obj != nil && obj.Kind != ast.Pkg
+}
+
+// use records that a lowering in the current scope uses the package path.
+func (v *nameVisitor) use(path string) {
+	v.l.uses[path] = true
+	if v.scope.Lookup(v.l.names[path]) != nil {
+		v.l.hidden[path] = true
+	}
+}
+
+// formatted records the operands in list declared as *[]byte.
+func (v *nameVisitor) formatted(list []ast.Expr) {
+	for _, x := range list {
+		if isBytes(v.typeOf(x)) {
+			v.l.bytes[x] = true
+		}
+	}
+}
+
+// walkList walks the expressions in list, which may be nil.
+func (v *nameVisitor) walkList(list []ast.Expr) {
+	for _, x := range list {
+		if x != nil {
+			ast.Walk(v, x)
+		}
+	}
+}
+
+// decl declares the names of d.
+func (v *nameVisitor) decl(d ast.Decl) {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil && d.Name != nil {
+			v.scope.Insert(&ast.Object{Kind: ast.Fun, Name: d.Name.Name, Type: d.Type})
+		}
+	case *ast.TypeDecl:
+		for _, spec := range d.Specs {
+			if spec.Name != nil {
+				v.scope.Insert(&ast.Object{Kind: ast.Typ, Name: spec.Name.Name})
+			}
+		}
+	case *ast.ConstDecl:
+		for _, spec := range d.Specs {
+			v.spec(ast.Con, spec.Names, spec.Type, spec.Values)
+		}
+	case *ast.VarDecl:
+		for _, spec := range d.Specs {
+			v.spec(ast.Var, spec.Names, spec.Type, spec.Values)
+		}
+	}
+}
+
+// spec declares the names of a const or var spec.
+func (v *nameVisitor) spec(kind ast.ObjKind, names []*ast.Ident, typ ast.Type, values []ast.Expr) {
+	for i, name := range names {
+		t := typ
+		if t == nil && len(values) == len(names) {
+			t = v.typeOf(values[i])
+		}
+		if name != nil {
+			v.scope.Insert(&ast.Object{Kind: kind, Name: name.Name, Type: t})
+		}
+	}
+}
+
+func (v *nameVisitor) signature(t *ast.FuncType) {
+	if t != nil {
+		v.fields(t.Params)
+		v.fields(t.Results)
+	}
+}
+
+// fields declares the names of a receiver, parameter or result list.
+func (v *nameVisitor) fields(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, f := range list.List {
+		for _, name := range f.Names {
+			v.declare(name, f.Type)
+		}
+	}
+}
+
+// declare adds x to the scope as a variable if it is a name.
+func (v *nameVisitor) declare(x ast.Expr, t ast.Type) {
+	if id, ok := x.(*ast.Ident); ok {
+		v.scope.Insert(&ast.Object{Kind: ast.Var, Name: id.Name, Type: t})
+	}
+}
+
+// typeOf returns the type of x where it is evident: the declared type of
+// a name, the signature of a function literal, or the type of the
+// composite literal whose address x takes.
+func (v *nameVisitor) typeOf(x ast.Expr) ast.Type {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if obj := v.scope.Lookup(x.Name); obj != nil {
+			return obj.Type
+		}
+	case *ast.ParenExpr:
+		return v.typeOf(x.X)
+	case *ast.FuncLit:
+		return x.Type
+	case *ast.UnaryExpr:
+		if lit, ok := x.X.(*ast.CompositeLit); ok && x.Op == ast.AND && lit.Type != nil {
+			return &ast.PointerType{Base: lit.Type}
+		}
+	}
+	return nil
+}
+
+// elemType returns the element type of the slice the call sort(s, less)
+// sorts, if evident: the type of the first parameter of less, or the
+// element type of s.
+func (v *nameVisitor) elemType(x *ast.CallExpr) ast.Type {
+	if fn, ok := v.typeOf(x.Args[1]).(*ast.FuncType); ok && fn.Params != nil && len(fn.Params.List) > 0 {
+		return fn.Params.List[0].Type
+	}
+	t := v.typeOf(x.Args[0])
+	if c, ok := t.(*ast.ConstType); ok {
+		t = c.Type
+	}
+	switch t := t.(type) {
+	case *ast.PointerType:
+		if s, ok := t.Base.(*ast.SliceType); ok {
+			return s.Elem
+		}
+	case *ast.SliceType:
+		if t.Pointer {
+			return t.Elem
+		}
+	}
+	return nil
+}
+
+// isBytes reports whether t is *[]byte, or a const view of it.
+func isBytes(t ast.Type) bool {
+	if c, ok := t.(*ast.ConstType); ok {
+		t = c.Type
+	}
+	var s *ast.SliceType
+	switch t := t.(type) {
+	case *ast.PointerType:
+		s, _ = t.Base.(*ast.SliceType)
+	case *ast.SliceType:
+		if t.Pointer {
+			s = t
+		}
+	}
+	if s == nil {
+		return false
+	}
+	switch e := s.Elem.(type) {
+	case *ast.Ident:
+		return e.Name == "byte" || e.Name == "uint8"
+	case *ast.BasicType:
+		return e.Kind == ast.Byte || e.Kind == ast.Uint8
+	}
+	return false
+}
+
+// importedName returns the name s imports its package under, assuming
+// it is the last element of the path.
+func importedName(s *ast.ImportSpec) string {
+	if s.Name != nil {
+		return s.Name.Name
+	}
+	if s.Path == nil {
+		return ""
+	}
+	path, err := strconv.Unquote(s.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// freshName returns base followed by a number, such that no identifier in
+// f and none of imports uses it.
+func freshName(f *ast.File, base string, imports []*ast.ImportDecl) string {
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	for _, d := range imports {
+		for _, s := range d.Specs {
+			used[importedName(s)] = true
+		}
+	}
+	for i := 2; ; i++ {
+		if name := base + strconv.Itoa(i); !used[name] {
+			return name
+		}
+	}
+}
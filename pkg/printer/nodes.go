@@ -0,0 +1,1312 @@
+package printer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// node prints any node.
+func (p *printer) node(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.File:
+		p.file(n)
+	case ast.Decl:
+		p.decl(n)
+		p.lineComment(n.End())
+	case ast.Stmt:
+		p.stmt(n)
+	case ast.Expr:
+		p.expr(n)
+	case *ast.FieldList:
+		p.fieldList(n, "(", ")")
+	case *ast.Field:
+		p.field(n)
+	case *ast.CommentGroup:
+		p.commentGroup(n)
+	default:
+		p.errorf(n.Pos(), "unsupported node %T", n)
+	}
+}
+
+// ============================================================================
+// Files and Declarations
+// ============================================================================
+
+// file prints a source file.
+func (p *printer) file(f *ast.File) {
+	if f.Package != nil {
+		p.preamble = true
+		p.beginLine(f.Package, false)
+		p.doc(f.Doc)
+		p.preamble = false
+		p.print("package ")
+		p.ident(f.Package.Name)
+		p.endLine(f.Package)
+	}
+
+	imports := f.Imports
+	if p.mode == Go {
+		imports = p.lower(f)
+	}
+
+	var prev ast.Decl
+	for _, d := range imports {
+		p.topLevel(prev, d)
+		prev = d
+	}
+	for _, d := range f.Decls {
+		p.topLevel(prev, d)
+		prev = d
+	}
+
+	p.flushComments(ast.Position{})
+}
+
+// addImport returns imports with spec added at the end of the first import
+// group, or of the first import declaration if none is grouped.
+func addImport(imports []*ast.ImportDecl, spec *ast.ImportSpec) []*ast.ImportDecl {
+	if len(imports) == 0 {
+		return []*ast.ImportDecl{{Specs: []*ast.ImportSpec{spec}}}
+	}
+	i := 0
+	for j, d := range imports {
+		if d.Lparen.IsValid() {
+			i = j
+			break
+		}
+	}
+	d := *imports[i]
+	d.Specs = append(d.Specs[:len(d.Specs):len(d.Specs)], spec)
+	out := append([]*ast.ImportDecl(nil), imports...)
+	out[i] = &d
+	return out
+}
+
+// importName returns the qualifier under which f imports path: "" for a
+// dot import, or the package name followed by a period.
+func importName(f *ast.File, path string) (string, bool) {
+	for _, d := range f.Imports {
+		for _, s := range d.Specs {
+			if s.Path == nil || s.Path.Value != strconv.Quote(path) {
+				continue
+			}
+			switch name := importedName(s); name {
+			case ".":
+				return "", true
+			case "", "_":
+			default:
+				return name + ".", true
+			}
+		}
+	}
+	return "", false
+}
+
+// topLevel prints a top-level declaration. It is separated from the
+// previous one by a blank line, except between declarations of the same
+// kind without doc comment that the source has on consecutive lines.
+func (p *printer) topLevel(prev, d ast.Decl) {
+	blank := prev == nil || !d.Pos().IsValid() || declDoc(d) != nil ||
+		fmt.Sprintf("%T", prev) != fmt.Sprintf("%T", d)
+	p.beginLine(d, blank)
+	p.decl(d)
+	p.endLine(d)
+}
+
+// declDoc returns the doc comment of d.
+func declDoc(d ast.Decl) *ast.CommentGroup {
+	switch d := d.(type) {
+	case *ast.ImportDecl:
+		return d.Doc
+	case *ast.ConstDecl:
+		return d.Doc
+	case *ast.VarDecl:
+		return d.Doc
+	case *ast.TypeDecl:
+		return d.Doc
+	case *ast.FuncDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+// decl prints a declaration, without a final line break.
+func (p *printer) decl(d ast.Decl) {
+	switch d := d.(type) {
+	case *ast.ImportDecl:
+		specs := make([]ast.Spec, len(d.Specs))
+		for i, s := range d.Specs {
+			specs[i] = s
+		}
+		p.genDecl(d.Doc, "import", d.Lparen, specs)
+	case *ast.ConstDecl:
+		specs := make([]ast.Spec, len(d.Specs))
+		for i, s := range d.Specs {
+			specs[i] = s
+		}
+		keyword := "const"
+		if p.mode == Go && !p.goConst(d) {
+			keyword = "var"
+		}
+		p.genDecl(d.Doc, keyword, d.Lparen, specs)
+	case *ast.VarDecl:
+		specs := make([]ast.Spec, len(d.Specs))
+		for i, s := range d.Specs {
+			specs[i] = s
+		}
+		p.genDecl(d.Doc, "var", d.Lparen, specs)
+	case *ast.TypeDecl:
+		specs := make([]ast.Spec, len(d.Specs))
+		for i, s := range d.Specs {
+			specs[i] = s
+		}
+		p.genDecl(d.Doc, "type", d.Lparen, specs)
+	case *ast.FuncDecl:
+		p.funcDecl(d)
+	default:
+		p.errorf(d.Pos(), "unsupported declaration %T", d)
+	}
+}
+
+// goConst reports whether the values of d are Go constants. Moxie consts
+// may also hold pointers and literals, which print as a Go var; the names
+// they declare are then recorded, as they are not Go constants either.
+func (p *printer) goConst(d *ast.ConstDecl) bool {
+	ok := true
+	for _, s := range d.Specs {
+		for _, x := range s.Values {
+			ok = ok && p.constant(x)
+		}
+	}
+	if ok {
+		return true
+	}
+	if p.vars == nil {
+		p.vars = make(map[string]bool)
+	}
+	for _, s := range d.Specs {
+		if len(s.Values) == 0 && len(s.Names) > 0 {
+			p.errorf(s.Pos(), "const %s repeats a value that is not a Go constant", s.Names[0].Name)
+		}
+		for _, name := range s.Names {
+			p.vars[name.Name] = true
+		}
+	}
+	return false
+}
+
+// constant reports whether x may be a Go constant expression: it is
+// built from literals, names and calls, such as conversions and len, of
+// them.
+func (p *printer) constant(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.BasicLit, *ast.SelectorExpr:
+		return true
+	case *ast.Ident:
+		return !p.vars[x.Name]
+	case *ast.ParenExpr:
+		return p.constant(x.X)
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case ast.ADD, ast.SUB, ast.NOT, ast.XOR:
+			return p.constant(x.X)
+		}
+	case *ast.BinaryExpr:
+		return p.constant(x.X) && p.constant(x.Y)
+	case *ast.CallExpr:
+		for _, arg := range x.Args {
+			if !p.constant(arg) {
+				return false
+			}
+		}
+		_, lit := x.Fun.(*ast.FuncLit)
+		return !lit
+	}
+	return false
+}
+
+// genDecl prints an import, const, var or type declaration. A declaration
+// with a single spec is printed without parentheses unless the source had
+// them.
+func (p *printer) genDecl(doc *ast.CommentGroup, keyword string, lparen ast.Position, specs []ast.Spec) {
+	p.doc(doc)
+	p.print(keyword, " ")
+	if len(specs) == 1 && !lparen.IsValid() {
+		p.spec(specs[0], " ")
+		p.comment(specComment(specs[0]))
+		return
+	}
+
+	p.print("(")
+	p.newline()
+	p.indent++
+	for _, s := range specs {
+		p.beginLine(s, false)
+		p.doc(specDoc(s))
+		p.spec(s, string(cellSep))
+		p.comment(specComment(s))
+		p.endLine(s)
+	}
+	p.indent--
+	p.print(")")
+}
+
+// specDoc returns the doc comment of s.
+func specDoc(s ast.Spec) *ast.CommentGroup {
+	switch s := s.(type) {
+	case *ast.ImportSpec:
+		return s.Doc
+	case *ast.ConstSpec:
+		return s.Doc
+	case *ast.VarSpec:
+		return s.Doc
+	case *ast.TypeSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// specComment returns the line comment of s.
+func specComment(s ast.Spec) *ast.CommentGroup {
+	switch s := s.(type) {
+	case *ast.ImportSpec:
+		return s.Comment
+	case *ast.ConstSpec:
+		return s.Comment
+	case *ast.VarSpec:
+		return s.Comment
+	case *ast.TypeSpec:
+		return s.Comment
+	}
+	return nil
+}
+
+// spec prints a spec. The names, type and values of a const or var spec
+// are separated by sep, which aligns them in a declaration group.
+func (p *printer) spec(s ast.Spec, sep string) {
+	switch s := s.(type) {
+	case *ast.ImportSpec:
+		if s.Name != nil {
+			p.ident(s.Name)
+			p.print(" ")
+		}
+		p.expr(s.Path)
+	case *ast.ConstSpec:
+		p.valueSpec(s.Names, s.Type, s.Values, sep)
+	case *ast.VarSpec:
+		p.valueSpec(s.Names, s.Type, s.Values, sep)
+	case *ast.TypeSpec:
+		p.ident(s.Name)
+		if s.TypeParams != nil {
+			p.fieldList(s.TypeParams, "[", "]")
+		}
+		if s.IsAlias() {
+			p.print(" =")
+		}
+		p.print(" ")
+		p.expr(s.Type)
+	default:
+		p.errorf(s.Pos(), "unsupported spec %T", s)
+	}
+}
+
+// valueSpec prints a const or var spec.
+func (p *printer) valueSpec(names []*ast.Ident, typ ast.Type, values []ast.Expr, sep string) {
+	p.identList(names)
+	if typ != nil {
+		p.print(sep)
+		p.expr(typ)
+	}
+	if len(values) > 0 {
+		p.print(sep, "= ")
+		p.exprList(values)
+	}
+}
+
+// funcDecl prints a function or method declaration.
+func (p *printer) funcDecl(d *ast.FuncDecl) {
+	p.doc(d.Doc)
+	p.print("func ")
+	if d.Recv != nil {
+		p.fieldList(d.Recv, "(", ")")
+		p.print(" ")
+	}
+	p.ident(d.Name)
+	if d.Type != nil {
+		if d.Type.TypeParams != nil {
+			p.fieldList(d.Type.TypeParams, "[", "]")
+		}
+		p.signature(d.Type)
+	}
+	if d.Body != nil {
+		p.print(" ")
+		p.block(d.Body)
+	}
+}
+
+// ============================================================================
+// Statements
+// ============================================================================
+
+// stmtList prints the statements of a block or case clause, one per line.
+func (p *printer) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		if _, ok := s.(*ast.EmptyStmt); ok || p.omitted(s) {
+			continue
+		}
+		p.beginLine(s, false)
+		p.stmt(s)
+		p.endLine(s)
+	}
+}
+
+// block prints a block statement.
+func (p *printer) block(b *ast.BlockStmt) {
+	p.print("{")
+	if b.Lbrace.IsValid() {
+		p.lastLine = b.Lbrace.Line
+	}
+	if len(b.List) == 0 && !p.commentsBefore(b.Rbrace) {
+		p.print("}")
+		return
+	}
+	if b.Lbrace.IsValid() && b.Rbrace.Line == b.Lbrace.Line && !p.commentsBefore(b.Rbrace) {
+		// Keep a block written on one line, as in func() { ch <- v }.
+		p.print(" ")
+		sep := ""
+		for _, s := range b.List {
+			if p.omitted(s) {
+				continue
+			}
+			p.print(sep)
+			p.stmt(s)
+			sep = "; "
+		}
+		p.print(" }")
+		return
+	}
+
+	p.newline()
+	p.indent++
+	p.stmtList(b.List)
+	if b.Rbrace.IsValid() {
+		p.flushComments(b.Rbrace)
+	}
+	p.indent--
+	p.print("}")
+}
+
+// omitted reports whether s is an assert statement omitted from a release
+// build.
+func (p *printer) omitted(s ast.Stmt) bool {
+	x, ok := s.(*ast.ExprStmt)
+	if !ok || !p.release || p.mode != Go {
+		return false
+	}
+	call, ok := x.X.(*ast.CallExpr)
+	return ok && p.builtin(call) == "assert" && builtinError(call, "assert") == ""
+}
+
+// commentsBefore reports whether comments remain to be printed before pos.
+func (p *printer) commentsBefore(pos ast.Position) bool {
+	return len(p.comments) > 0 && pos.IsValid() && p.comments[0].Pos().Offset < pos.Offset
+}
+
+// stmt prints a statement, without a final line break.
+func (p *printer) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.BadStmt:
+		p.errorf(s.Pos(), "cannot print a bad statement")
+	case *ast.DeclStmt:
+		p.decl(s.Decl)
+	case *ast.EmptyStmt:
+		// nothing to print
+	case *ast.LabeledStmt:
+		p.indent-- // Labels are outdented, as by gofmt
+		p.ident(s.Label)
+		p.print(":")
+		p.indent++
+		if _, ok := s.Stmt.(*ast.EmptyStmt); !ok && s.Stmt != nil {
+			p.newline()
+			p.stmt(s.Stmt)
+		}
+	case *ast.ExprStmt:
+		if call, ok := s.X.(*ast.CallExpr); ok && p.mode == Go && p.builtin(call) == "assert" {
+			p.assertStmt(call)
+			return
+		}
+		p.expr(s.X)
+	case *ast.SendStmt:
+		p.expr(s.Chan)
+		p.print(" <- ")
+		p.expr(s.Value)
+	case *ast.IncDecStmt:
+		p.expr(s.X)
+		p.print(s.Tok.String())
+	case *ast.AssignStmt:
+		p.exprList(s.Lhs)
+		p.print(" ", s.Tok.String(), " ")
+		p.exprList(s.Rhs)
+	case *ast.GoStmt:
+		p.print("go ")
+		p.expr(s.Call)
+	case *ast.DeferStmt:
+		p.print("defer ")
+		p.expr(s.Call)
+	case *ast.ReturnStmt:
+		p.print("return")
+		if len(s.Results) > 0 {
+			p.print(" ")
+			p.exprList(s.Results)
+		}
+	case *ast.BranchStmt:
+		p.print(s.Tok.String())
+		if s.Label != nil {
+			p.print(" ")
+			p.ident(s.Label)
+		}
+	case *ast.BlockStmt:
+		p.block(s)
+	case *ast.IfStmt:
+		p.ifStmt(s)
+	case *ast.SwitchStmt:
+		p.print("switch ")
+		p.header(s.Init, s.Tag)
+		p.clauses(s.Body)
+	case *ast.TypeSwitchStmt:
+		p.print("switch ")
+		if s.Init != nil {
+			p.stmt(s.Init)
+			p.print("; ")
+		}
+		p.stmt(s.Assign)
+		p.print(" ")
+		p.clauses(s.Body)
+	case *ast.SelectStmt:
+		p.print("select ")
+		p.clauses(s.Body)
+	case *ast.ForStmt:
+		p.print("for ")
+		if s.Init != nil || s.Post != nil {
+			if s.Init != nil {
+				p.stmt(s.Init)
+			}
+			p.print("; ")
+			if s.Cond != nil {
+				p.expr(s.Cond)
+			}
+			p.print("; ")
+			if s.Post != nil {
+				p.stmt(s.Post)
+			}
+			p.print(" ")
+		} else if s.Cond != nil {
+			p.expr(s.Cond)
+			p.print(" ")
+		}
+		p.block(s.Body)
+	case *ast.RangeStmt:
+		p.print("for ")
+		if s.Key != nil {
+			p.expr(s.Key)
+			if s.Value != nil {
+				p.print(", ")
+				p.expr(s.Value)
+			}
+			p.print(" ", s.Tok.String(), " ")
+		}
+		p.print("range ")
+		p.expr(s.X)
+		p.print(" ")
+		p.block(s.Body)
+	default:
+		p.errorf(s.Pos(), "unsupported statement %T", s)
+	}
+}
+
+// header prints the init statement and expression of an if or switch
+// statement, followed by a space.
+func (p *printer) header(init ast.Stmt, x ast.Expr) {
+	if init != nil {
+		p.stmt(init)
+		p.print("; ")
+	}
+	if x != nil {
+		p.expr(x)
+		p.print(" ")
+	}
+}
+
+// ifStmt prints an if statement with its else branches.
+func (p *printer) ifStmt(s *ast.IfStmt) {
+	p.print("if ")
+	p.header(s.Init, s.Cond)
+	p.block(s.Body)
+	switch e := s.Else.(type) {
+	case nil:
+	case *ast.IfStmt:
+		p.print(" else ")
+		p.ifStmt(e)
+	default:
+		p.print(" else ")
+		p.stmt(e)
+	}
+}
+
+// clauses prints the body of a switch or select statement. Case clauses
+// are indented like the statement itself.
+func (p *printer) clauses(body *ast.BlockStmt) {
+	p.print("{")
+	if body.Lbrace.IsValid() {
+		p.lastLine = body.Lbrace.Line
+	}
+	p.newline()
+	for _, s := range body.List {
+		p.beginLine(s, false)
+		switch c := s.(type) {
+		case *ast.CaseClause:
+			if c.List == nil {
+				p.print("default:")
+			} else {
+				p.print("case ")
+				p.exprList(c.List)
+				p.print(":")
+			}
+			p.caseBody(c.Colon, c.Body)
+		case *ast.CommClause:
+			if c.Comm == nil {
+				p.print("default:")
+			} else {
+				p.print("case ")
+				p.stmt(c.Comm)
+				p.print(":")
+			}
+			p.caseBody(c.Colon, c.Body)
+		default:
+			p.errorf(s.Pos(), "unexpected %T in switch or select", s)
+		}
+	}
+	if body.Rbrace.IsValid() {
+		p.flushComments(body.Rbrace)
+	}
+	p.print("}")
+}
+
+// caseBody prints the statements of a case clause after its colon.
+func (p *printer) caseBody(colon ast.Position, body []ast.Stmt) {
+	p.lineComment(colon.Advance(":"))
+	p.newline()
+	if colon.IsValid() {
+		p.lastLine = colon.Line
+	}
+	p.indent++
+	p.stmtList(body)
+	p.indent--
+}
+
+// ============================================================================
+// Expressions
+// ============================================================================
+
+// exprList prints a comma-separated list of expressions.
+func (p *printer) exprList(list []ast.Expr) {
+	for i, x := range list {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.expr(x)
+	}
+}
+
+// args prints the arguments of a call, keeping the line breaks between
+// them. Continuation lines are indented one level.
+func (p *printer) args(lparen ast.Position, args []ast.Expr, rparen ast.Position) {
+	prev := lparen
+	broken := false
+	for i, x := range args {
+		if i > 0 {
+			p.print(",")
+		}
+		if pos := x.Pos(); prev.IsValid() && pos.IsValid() && pos.Line > prev.Line {
+			if !broken {
+				p.indent++
+				broken = true
+			}
+			p.newline()
+		} else if i > 0 {
+			p.print(" ")
+		}
+		p.expr(x)
+		prev = x.End()
+	}
+	if !broken {
+		return
+	}
+	if rparen.IsValid() && rparen.Line > prev.Line {
+		p.print(",")
+		p.indent--
+		p.newline()
+		return
+	}
+	p.indent--
+}
+
+// identList prints a comma-separated list of identifiers.
+func (p *printer) identList(list []*ast.Ident) {
+	for i, x := range list {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.ident(x)
+	}
+}
+
+// ident prints an identifier.
+func (p *printer) ident(x *ast.Ident) {
+	if x == nil {
+		p.print("_")
+		return
+	}
+	p.print(x.Name)
+}
+
+// expr prints an expression or type.
+func (p *printer) expr(x ast.Expr) {
+	p.binaryOperand(x, 0)
+}
+
+// binaryOperand prints x as an operand of a binary operator of precedence
+// prec, parenthesizing binary expressions that bind less tightly.
+func (p *printer) binaryOperand(x ast.Expr, prec int) {
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op.Precedence() < prec {
+		p.print("(")
+		p.binaryOperand(b, 0)
+		p.print(")")
+		return
+	}
+
+	switch x := x.(type) {
+	case nil:
+		p.errorf(ast.Position{}, "missing expression")
+	case *ast.BadExpr:
+		p.errorf(x.Pos(), "cannot print a bad expression")
+	case *ast.Ident:
+		p.ident(x)
+	case *ast.BasicLit:
+		p.print(verbatim(x.Value))
+	case *ast.ParenExpr:
+		p.print("(")
+		p.expr(x.X)
+		p.print(")")
+	case *ast.SelectorExpr:
+		p.expr(x.X)
+		p.print(".")
+		p.ident(x.Sel)
+	case *ast.IndexExpr:
+		if p.mode == Go && p.setType(x) {
+			p.print("map[")
+			p.expr(x.Index)
+			p.print("]struct{}")
+			return
+		}
+		p.expr(x.X)
+		p.print("[")
+		p.expr(x.Index)
+		p.print("]")
+	case *ast.IndexListExpr:
+		p.expr(x.X)
+		p.print("[")
+		p.exprList(x.Indices)
+		p.print("]")
+	case *ast.SliceExpr:
+		p.expr(x.X)
+		p.print("[")
+		if x.Low != nil {
+			p.expr(x.Low)
+		}
+		p.print(":")
+		if x.High != nil {
+			p.expr(x.High)
+		}
+		if x.Slice3 {
+			p.print(":")
+			if x.Max != nil {
+				p.expr(x.Max)
+			}
+		}
+		p.print("]")
+	case *ast.TypeAssertExpr:
+		p.expr(x.X)
+		p.print(".(")
+		if x.Type == nil {
+			p.print("type")
+		} else {
+			p.expr(x.Type)
+		}
+		p.print(")")
+	case *ast.CallExpr:
+		if name := p.builtin(x); name != "" && p.mode == Go {
+			switch {
+			case builtinError(x, name) != "":
+				p.errorf(x.Pos(), "%s", builtinError(x, name))
+			case name == "sort":
+				p.sortCall(x)
+			default:
+				p.errorf(x.Pos(), "assert outside an expression statement has no Go equivalent")
+			}
+			return
+		}
+		p.expr(x.Fun)
+		p.print("(")
+		p.args(x.Lparen, x.Args, x.Rparen)
+		if x.Ellipsis.IsValid() {
+			p.print("...")
+		}
+		p.print(")")
+	case *ast.StarExpr:
+		p.print("*")
+		p.expr(x.X)
+	case *ast.UnaryExpr:
+		p.print(x.Op.String())
+		if u, ok := x.X.(*ast.UnaryExpr); ok && joinsOperator(x.Op, u.Op) {
+			p.print(" ") // - -x, not --x
+		}
+		p.binaryOperand(x.X, 6)
+	case *ast.BinaryExpr:
+		prec := x.Op.Precedence()
+		p.binaryOperand(x.X, prec)
+		p.print(" ", x.Op.String(), " ")
+		p.binaryOperand(x.Y, prec+1)
+	case *ast.KeyValueExpr:
+		p.expr(x.Key)
+		p.print(": ")
+		p.expr(x.Value)
+	case *ast.CompositeLit:
+		if x.Type != nil {
+			p.expr(x.Type)
+		}
+		p.elements(x.Lbrace, x.Elts, x.Rbrace)
+	case *ast.FuncLit:
+		p.print("func")
+		p.signature(x.Type)
+		p.print(" ")
+		p.block(x.Body)
+	case *ast.Ellipsis:
+		p.print("...")
+		if x.Elt != nil {
+			p.expr(x.Elt)
+		}
+	case *ast.SliceLit:
+		p.print("&[]")
+		p.expr(x.Type)
+		p.elements(x.Lbrace, x.Elts, x.Rbrace)
+	case *ast.MapLit:
+		p.print("&map[")
+		p.expr(x.Key)
+		p.print("]")
+		p.expr(x.Value)
+		p.elements(x.Lbrace, x.Elts, x.Rbrace)
+	case *ast.SetLit:
+		p.setLit(x)
+	case *ast.ChanLit:
+		p.chanLit(x)
+	case *ast.InterpolatedString:
+		p.interpolatedString(x)
+	case *ast.TypeCoercion:
+		if p.mode == Go {
+			p.errorf(x.Pos(), "slice cast has no Go equivalent; lower it before printing Go")
+			return
+		}
+		p.print("(")
+		p.expr(x.Target)
+		if x.Endian != nil {
+			p.print(", ")
+			p.ident(x.Endian)
+		}
+		p.print(")(")
+		p.expr(x.Expr)
+		p.print(")")
+	case *ast.FFICall:
+		if p.mode == Go {
+			p.errorf(x.Pos(), "%s call has no Go equivalent; lower it before printing Go", x.Name.Name)
+			return
+		}
+		p.ident(x.Name)
+		p.print("[")
+		p.expr(x.Type)
+		p.print("](")
+		p.exprList(x.Args)
+		p.print(")")
+	default:
+		p.typ(x)
+	}
+}
+
+// joinsOperator reports whether the unary operators op and next, printed
+// without space, would read as a different token.
+func joinsOperator(op, next ast.Token) bool {
+	switch op {
+	case ast.ADD, ast.SUB:
+		return next == op
+	case ast.AND:
+		return next == ast.XOR || next == ast.AND
+	}
+	return false
+}
+
+// elements prints the braced element list of a composite literal. If the
+// source spans several lines, the elements keep their line breaks and
+// every line ends with a comma.
+func (p *printer) elements(lbrace ast.Position, elts []ast.Expr, rbrace ast.Position) {
+	p.print("{")
+	if len(elts) == 0 || !lbrace.IsValid() || rbrace.Line == lbrace.Line {
+		p.exprList(elts)
+		p.print("}")
+		return
+	}
+
+	p.lastLine = lbrace.Line
+	p.newline()
+	p.indent++
+	var prev ast.Expr
+	for _, x := range elts {
+		if prev != nil && x.Pos().IsValid() && x.Pos().Line == prev.End().Line {
+			p.print(" ")
+		} else {
+			if prev != nil {
+				p.endLine(prev)
+			}
+			p.beginLine(x, false)
+		}
+		if kv, ok := x.(*ast.KeyValueExpr); ok {
+			p.expr(kv.Key) // Values of consecutive keyed elements are aligned
+			p.print(":", string(cellSep))
+			p.expr(kv.Value)
+		} else {
+			p.expr(x)
+		}
+		p.print(",")
+		prev = x
+	}
+	p.endLine(prev)
+	p.flushComments(rbrace)
+	p.indent--
+	p.print("}")
+}
+
+// setLit prints a set literal. In Go a set is a map to empty structs, and
+// each element becomes a key.
+func (p *printer) setLit(x *ast.SetLit) {
+	if p.mode == Go && p.setType(x) {
+		keys := make([]ast.Expr, len(x.Elts))
+		for i, e := range x.Elts {
+			keys[i] = &ast.KeyValueExpr{Key: e, Value: &ast.CompositeLit{Lbrace: e.End(), Rbrace: e.End()}}
+		}
+		p.print("&map[")
+		p.expr(x.Elem)
+		p.print("]struct{}")
+		p.elements(x.Lbrace, keys, x.Rbrace)
+		return
+	}
+
+	p.print("&set[")
+	p.expr(x.Elem)
+	p.print("]")
+	p.elements(x.Lbrace, x.Elts, x.Rbrace)
+}
+
+// chanLit prints a channel literal. Go has no channel literals; there the
+// channel is made and its address taken in a function literal.
+func (p *printer) chanLit(x *ast.ChanLit) {
+	typ := &ast.ChanType{Dir: x.Dir, Value: x.Type}
+	if p.mode == Go {
+		p.print("func() *")
+		p.expr(typ)
+		p.print(" { c := make(")
+		p.expr(typ)
+		if x.Cap != nil {
+			p.print(", ")
+			p.expr(x.Cap)
+		}
+		p.print("); return &c }()")
+		return
+	}
+
+	p.print("&")
+	p.expr(typ)
+	p.print("{")
+	if x.Cap != nil {
+		if x.Colon.IsValid() || !x.Lbrace.IsValid() {
+			p.print("cap: ")
+		}
+		p.expr(x.Cap)
+	}
+	p.print("}")
+}
+
+// interpolatedString prints a string literal with embedded expressions. In
+// Go it becomes a fmt.Sprintf call formatting each expression with %v, or
+// with %s if it is declared as *[]byte, which is dereferenced.
+func (p *printer) interpolatedString(x *ast.InterpolatedString) {
+	if p.mode == Go {
+		if len(x.Exprs) == 0 {
+			p.print(verbatim(`"` + strings.Join(x.Text, "") + `"`))
+			return
+		}
+		// The text is decoded before escaping %, which an escape
+		// sequence such as \x25 may also produce.
+		var format strings.Builder
+		for i, text := range x.Text {
+			if i > 0 {
+				if p.lowering != nil && p.lowering.bytes[x.Exprs[i-1]] {
+					format.WriteString("%s")
+				} else {
+					format.WriteString("%v")
+				}
+			}
+			s, err := strconv.Unquote(`"` + text + `"`)
+			if err != nil {
+				p.errorf(x.Pos(), "invalid string literal %s", x.Value)
+			}
+			format.WriteString(strings.ReplaceAll(s, "%", "%%"))
+		}
+		p.print(p.qualifier("fmt"), "Sprintf(", verbatim(strconv.Quote(format.String())))
+		for _, e := range x.Exprs {
+			p.print(", ")
+			if p.lowering != nil && p.lowering.bytes[e] {
+				p.print("*")
+			}
+			p.expr(e)
+		}
+		p.print(")")
+		return
+	}
+
+	for i, text := range x.Text {
+		if i == 0 {
+			p.print(`"`)
+		} else {
+			p.print("${")
+			p.expr(x.Exprs[i-1])
+			p.print("}")
+		}
+		p.print(verbatim(strings.ReplaceAll(text, "${", "$${")))
+	}
+	p.print(`"`)
+}
+
+// sortCall prints a call of the builtin sort in Go. sort(s) becomes
+// slices.Sort(*s). sort(s, less) becomes slices.SortFunc(*s, cmp), where
+// cmp is built from less by a function literal applied to it, so that
+// less is evaluated once, after s. The literal needs the element type of
+// the slice, which must be evident from the declaration of s or less.
+func (p *printer) sortCall(x *ast.CallExpr) {
+	if len(x.Args) == 1 {
+		p.print(p.qualifier("slices"), "Sort(")
+		p.expr(&ast.UnaryExpr{Op: ast.MUL, X: x.Args[0]})
+		p.print(")")
+		return
+	}
+
+	var elem ast.Type
+	if p.lowering != nil {
+		elem = p.lowering.elems[x]
+	} else {
+		elem = (&nameVisitor{scope: ast.NewScope(nil)}).elemType(x)
+	}
+	if elem == nil {
+		p.errorf(x.Pos(), "cannot lower sort: the element type of the slice is unknown")
+		return
+	}
+	sig := func(result string) {
+		p.print("func(a, b ")
+		p.expr(elem)
+		p.print(") ", result)
+	}
+
+	p.print(p.qualifier("slices"), "SortFunc(")
+	p.expr(&ast.UnaryExpr{Op: ast.MUL, X: x.Args[0]})
+	p.print(", func(less ")
+	sig("bool")
+	p.print(") ")
+	sig("int")
+	p.print(" {")
+	p.newline()
+	p.indent++
+	p.print("return ")
+	sig("int")
+	p.print(" {")
+	p.newline()
+	p.indent++
+	for _, line := range []string{"if less(a, b) {", "\treturn -1", "}", "if less(b, a) {", "\treturn 1", "}", "return 0"} {
+		p.print(line)
+		p.newline()
+	}
+	p.indent--
+	p.print("}")
+	p.newline()
+	p.indent--
+	p.print("}(")
+	p.expr(x.Args[1])
+	p.print("))")
+}
+
+// assertStmt prints a call of the builtin assert in Go, as an if
+// statement that panics if the condition is false, or nothing in a release
+// build. The panic message has the Moxie source position and the message
+// arguments, if any, formatted with fmt.Sprint; names declared as *[]byte
+// are converted to strings.
+func (p *printer) assertStmt(x *ast.CallExpr) {
+	if msg := builtinError(x, "assert"); msg != "" {
+		p.errorf(x.Pos(), "%s", msg)
+		return
+	}
+	if p.release {
+		return
+	}
+	msg := "assertion failed"
+	if pos := x.Pos(); pos.IsValid() {
+		msg = fmt.Sprintf("%s:%d: %s", pos.Filename, pos.Line, msg)
+	}
+
+	p.print("if ")
+	if not, ok := x.Args[0].(*ast.UnaryExpr); ok && not.Op == ast.NOT {
+		p.expr(not.X)
+	} else {
+		p.expr(&ast.UnaryExpr{Op: ast.NOT, X: x.Args[0]})
+	}
+	p.print(" {")
+	p.newline()
+	p.indent++
+	if len(x.Args) == 1 {
+		p.print("panic(", strconv.Quote(msg), ")")
+	} else {
+		p.print("panic(", strconv.Quote(msg+": "), " + ", p.qualifier("fmt"), "Sprint(")
+		for i, arg := range x.Args[1:] {
+			if i > 0 {
+				p.print(", ")
+			}
+			if p.lowering != nil && p.lowering.bytes[arg] {
+				p.print("string(*")
+				p.expr(arg)
+				p.print(")")
+			} else {
+				p.expr(arg)
+			}
+		}
+		p.print("))")
+	}
+	p.newline()
+	p.indent--
+	p.print("}")
+}
+
+// ============================================================================
+// Types
+// ============================================================================
+
+// typ prints a type.
+func (p *printer) typ(x ast.Expr) {
+	switch t := x.(type) {
+	case *ast.BasicType:
+		p.print(basicTypeNames[t.Kind])
+	case *ast.PointerType:
+		p.print("*")
+		p.expr(t.Base)
+	case *ast.SliceType:
+		if t.Pointer {
+			p.print("*")
+		}
+		p.print("[]")
+		p.expr(t.Elem)
+	case *ast.ArrayType:
+		p.print("[")
+		if t.Len != nil {
+			p.expr(t.Len)
+		} else {
+			p.print("...")
+		}
+		p.print("]")
+		p.expr(t.Elem)
+	case *ast.MapType:
+		if t.Pointer {
+			p.print("*")
+		}
+		p.print("map[")
+		p.expr(t.Key)
+		p.print("]")
+		p.expr(t.Value)
+	case *ast.ChanType:
+		if t.Pointer {
+			p.print("*")
+		}
+		switch t.Dir {
+		case ast.ChanSend:
+			p.print("chan<- ")
+		case ast.ChanRecv:
+			p.print("<-chan ")
+		default:
+			p.print("chan ")
+		}
+		if inner, ok := t.Value.(*ast.ChanType); ok && t.Dir != ast.ChanRecv && inner.Dir == ast.ChanRecv && !inner.Pointer {
+			p.print("(") // chan (<-chan T)
+			p.expr(inner)
+			p.print(")")
+		} else {
+			p.expr(t.Value)
+		}
+	case *ast.StructType:
+		p.print("struct")
+		p.fieldBlock(t.Fields, false)
+	case *ast.InterfaceType:
+		p.print("interface")
+		p.fieldBlock(t.Methods, true)
+	case *ast.FuncType:
+		p.print("func")
+		if t.TypeParams != nil {
+			p.fieldList(t.TypeParams, "[", "]")
+		}
+		p.signature(t)
+	case *ast.ParenType:
+		p.print("(")
+		p.expr(t.X)
+		p.print(")")
+	case *ast.TildeType:
+		p.print("~")
+		p.expr(t.Type)
+	case *ast.ConstType:
+		// Go has no read-only types; pkg/check enforces them.
+		if p.mode == Moxie {
+			p.print("const ")
+		}
+		p.expr(t.Type)
+	case *ast.UnionType:
+		for i, term := range t.Terms {
+			if i > 0 {
+				p.print(" | ")
+			}
+			p.expr(term)
+		}
+	default:
+		p.errorf(x.Pos(), "unsupported expression %T", x)
+	}
+}
+
+// basicTypeNames maps basic type kinds to their names.
+var basicTypeNames = [...]string{
+	ast.Invalid:    "invalid",
+	ast.Bool:       "bool",
+	ast.Int:        "int",
+	ast.Int8:       "int8",
+	ast.Int16:      "int16",
+	ast.Int32:      "int32",
+	ast.Int64:      "int64",
+	ast.Uint:       "uint",
+	ast.Uint8:      "uint8",
+	ast.Uint16:     "uint16",
+	ast.Uint32:     "uint32",
+	ast.Uint64:     "uint64",
+	ast.Uintptr:    "uintptr",
+	ast.Float32:    "float32",
+	ast.Float64:    "float64",
+	ast.Complex64:  "complex64",
+	ast.Complex128: "complex128",
+	ast.String:     "string",
+	ast.Byte:       "byte",
+	ast.Rune:       "rune",
+}
+
+// signature prints the parameters and results of a function type.
+func (p *printer) signature(t *ast.FuncType) {
+	if t.Params != nil {
+		p.fieldList(t.Params, "(", ")")
+	} else {
+		p.print("()")
+	}
+
+	results := t.Results
+	if results == nil || len(results.List) == 0 {
+		return
+	}
+	p.print(" ")
+	if len(results.List) == 1 && len(results.List[0].Names) == 0 && !results.Opening.IsValid() {
+		p.expr(results.List[0].Type)
+		return
+	}
+	p.fieldList(results, "(", ")")
+}
+
+// fieldList prints parameters, results or type parameters on one line.
+func (p *printer) fieldList(list *ast.FieldList, open, close string) {
+	p.print(open)
+	for i, f := range list.List {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.field(f)
+	}
+	p.print(close)
+}
+
+// fieldBlock prints the fields of a struct or the elements of an
+// interface, one per line.
+func (p *printer) fieldBlock(list *ast.FieldList, iface bool) {
+	if list == nil || len(list.List) == 0 && !p.commentsBefore(list.Closing) {
+		p.print("{}")
+		return
+	}
+	p.print(" {")
+	if list.Opening.IsValid() {
+		p.lastLine = list.Opening.Line
+	}
+
+	p.newline()
+	p.indent++
+	for _, f := range list.List {
+		p.beginLine(f, false)
+		p.doc(f.Doc)
+		if ft, ok := f.Type.(*ast.FuncType); ok && iface && len(f.Names) == 1 {
+			p.ident(f.Names[0]) // Method: name(params) results
+			p.signature(ft)
+		} else {
+			p.structField(f)
+		}
+		p.comment(f.Comment)
+		p.endLine(f)
+	}
+	if list.Closing.IsValid() {
+		p.flushComments(list.Closing)
+	}
+	p.indent--
+	p.print("}")
+}
+
+// structField prints a struct field or an embedded interface element,
+// aligning its type and tag with the neighbouring fields.
+func (p *printer) structField(f *ast.Field) {
+	p.identList(f.Names)
+	if len(f.Names) > 0 {
+		p.print(string(cellSep))
+	}
+	p.expr(f.Type)
+	if f.Tag != nil {
+		p.print(string(cellSep), verbatim(f.Tag.Value))
+	}
+}
+
+// field prints a parameter.
+func (p *printer) field(f *ast.Field) {
+	p.identList(f.Names)
+	if f.Type != nil {
+		if len(f.Names) > 0 {
+			p.print(" ")
+		}
+		p.expr(f.Type)
+	}
+	if f.Tag != nil {
+		p.print(" ", verbatim(f.Tag.Value))
+	}
+}
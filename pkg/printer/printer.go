@@ -0,0 +1,261 @@
+// Package printer renders pkg/ast nodes as source code.
+//
+// The printer writes either Moxie source, which the parser in pkg/antlr
+// reads back into an equivalent AST, or Go source for the Go toolchain.
+// Comments in File.Comments are emitted at their source positions. When
+// printing a file without them, or a single node, the Doc and Comment
+// fields of declarations, specs and fields are printed instead.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"go/format"
+	"io"
+	"strings"
+
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// Mode selects the output language.
+type Mode int
+
+const (
+	Moxie Mode = iota // Moxie source, as accepted by the parser
+	Go                // Go source, formatted with go/format
+)
+
+// Config controls the printer output.
+type Config struct {
+	Mode Mode
+
+	// Release omits assert statements, arguments included, from Go
+	// output. Names used only in them are then unused in Go.
+	Release bool
+}
+
+// Fprint prints node as Moxie source to w.
+func Fprint(w io.Writer, node ast.Node) error {
+	return (&Config{Mode: Moxie}).Fprint(w, node)
+}
+
+// Fprint prints node to w in the configured mode. Node may be a file, a
+// declaration, a statement or an expression.
+//
+// In Go mode, Moxie constructs without a Go equivalent, such as slice
+// casts and dlsym calls, are errors: they must be lowered before printing.
+// Files are formatted with go/format, which also rejects any output that
+// is not valid Go.
+func (cfg *Config) Fprint(w io.Writer, node ast.Node) error {
+	p := &printer{mode: cfg.Mode, release: cfg.Release, docs: true}
+	if file, ok := node.(*ast.File); ok && len(file.Comments) > 0 {
+		p.comments = file.Comments
+		p.docs = false
+	}
+	p.node(node)
+	if p.err != nil {
+		return p.err
+	}
+
+	out := align(p.buf.Bytes())
+	if _, ok := node.(*ast.File); ok && cfg.Mode == Go {
+		src, err := format.Source(out)
+		if err != nil {
+			return fmt.Errorf("printer: invalid Go output: %v", err)
+		}
+		out = src
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// printer holds the state of a single Fprint call.
+type printer struct {
+	mode    Mode
+	release bool // Omit assert statements in Go output
+	buf     bytes.Buffer
+	err     error
+
+	indent     int  // Current indentation level
+	needIndent bool // Indentation is due before the next text
+
+	comments []*ast.CommentGroup // Comments still to print, in source order
+	docs     bool                // Print Doc and Comment fields instead of comments
+	lastLine int                 // Source line of the last node or comment printed
+
+	preamble    bool // Printing the comments before the package clause
+	constrained bool // A build constraint was printed
+
+	lowering   *lowering         // Names the Go lowerings of the file depend on
+	qualifiers map[string]string // Qualifiers of the packages they use, by path
+	vars       map[string]bool   // Consts printed as var in Go output
+}
+
+// errorf records the first error of the print run.
+func (p *printer) errorf(pos ast.Position, format string, args ...interface{}) {
+	if p.err == nil {
+		msg := fmt.Sprintf(format, args...)
+		if pos.IsValid() {
+			msg = pos.String() + ": " + msg
+		}
+		p.err = fmt.Errorf("printer: %s", msg)
+	}
+}
+
+// print writes the strings in order, indenting at the start of a line.
+func (p *printer) print(args ...string) {
+	for _, s := range args {
+		if s == "" {
+			continue
+		}
+		if p.needIndent {
+			p.buf.WriteString(strings.Repeat("\t", p.indent))
+			p.needIndent = false
+		}
+		p.buf.WriteString(s)
+	}
+}
+
+// newline ends the current line.
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.needIndent = true
+}
+
+// ============================================================================
+// Comments and line breaks
+// ============================================================================
+
+// separate prints a blank line before a node at pos where the source has
+// one, or always if force is set. There is never more than one blank line,
+// nor one at the start of the output or after an opening brace.
+func (p *printer) separate(pos ast.Position, force bool) {
+	if !force && !(pos.IsValid() && p.lastLine > 0 && pos.Line > p.lastLine+1) {
+		return
+	}
+	out := p.buf.Bytes()
+	if len(out) == 0 || bytes.HasSuffix(out, []byte("\n\n")) ||
+		bytes.HasSuffix(out, []byte("{\n")) || bytes.HasSuffix(out, []byte("(\n")) {
+		return
+	}
+	p.newline()
+}
+
+// flushComments prints the comment groups before pos on lines of their
+// own. An invalid pos flushes all remaining comments.
+func (p *printer) flushComments(pos ast.Position) {
+	for len(p.comments) > 0 {
+		g := p.comments[0]
+		if pos.IsValid() && g.Pos().Offset >= pos.Offset {
+			return
+		}
+		p.comments = p.comments[1:]
+		p.separate(g.Pos(), false)
+		p.commentGroup(g)
+		p.newline()
+		p.lastLine = g.End().Line
+	}
+}
+
+// lineComment prints the comment groups that start on the line where a
+// node ending at end ends, after the node.
+func (p *printer) lineComment(end ast.Position) {
+	for len(p.comments) > 0 && end.IsValid() {
+		g := p.comments[0]
+		if g.Pos().Line != end.Line || g.Pos().Offset < end.Offset {
+			return
+		}
+		p.comments = p.comments[1:]
+		p.print(string(cellSep))
+		p.commentGroup(g)
+		p.lastLine = g.End().Line
+	}
+}
+
+// doc prints the doc comment of a node outside of a file with comments.
+func (p *printer) doc(g *ast.CommentGroup) {
+	if g == nil || !p.docs {
+		return
+	}
+	p.commentGroup(g)
+	p.newline()
+}
+
+// comment prints the line comment of a node outside of a file with
+// comments.
+func (p *printer) comment(g *ast.CommentGroup) {
+	if g == nil || !p.docs {
+		return
+	}
+	p.print(string(cellSep))
+	p.commentGroup(g)
+}
+
+// commentGroup prints the comments of g, each line comment on a line of
+// its own.
+func (p *printer) commentGroup(g *ast.CommentGroup) {
+	for i, c := range g.List {
+		if i > 0 {
+			if c.Slash.Line > g.List[i-1].End().Line || strings.HasPrefix(g.List[i-1].Text, "//") {
+				p.newline()
+			} else {
+				p.print(" ")
+			}
+		}
+		text := c.Text
+		if p.mode == Go {
+			text = p.goDirective(c)
+		}
+		p.print(verbatim(text))
+	}
+}
+
+// goDirective returns the text of comment c in Go output: a Moxie build
+// constraint, //moxie:build expr, before the package clause becomes the
+// //go:build line Go reads. Elsewhere a comment is kept as is. A file
+// has at most one build constraint, of either form.
+func (p *printer) goDirective(c *ast.Comment) string {
+	if !p.preamble {
+		return c.Text
+	}
+	line := c.Text
+	if expr, ok := strings.CutPrefix(line, "//moxie:build"); ok && (expr == "" || expr[0] == ' ' || expr[0] == '\t') {
+		line = "//go:build" + expr
+	}
+	if !constraint.IsGoBuild(line) {
+		return c.Text
+	}
+	if p.constrained {
+		p.errorf(c.Slash, "multiple build constraints")
+	}
+	p.constrained = true
+	if _, err := constraint.Parse(line); err != nil {
+		expr := strings.TrimPrefix(line, "//go:build")
+		p.errorf(c.Slash, "invalid build constraint %q: %v", strings.TrimSpace(expr), err)
+	}
+	return line
+}
+
+// beginLine prepares printing a node that starts a line: it flushes the
+// comments before it and preserves a blank line before it. If blank is
+// set, a blank line separates the node and its comments from the previous
+// line.
+func (p *printer) beginLine(n ast.Node, blank bool) {
+	pos := n.Pos()
+	p.separate(ast.Position{}, blank)
+	if pos.IsValid() {
+		p.flushComments(pos)
+	}
+	p.separate(pos, false)
+}
+
+// endLine finishes a line holding node n, with its line comment.
+func (p *printer) endLine(n ast.Node) {
+	end := n.End()
+	p.lineComment(end)
+	p.newline()
+	if end.IsValid() && end.Line > p.lastLine {
+		p.lastLine = end.Line
+	}
+}
@@ -0,0 +1,623 @@
+package printer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mleku/moxie/pkg/antlr"
+	"github.com/mleku/moxie/pkg/ast"
+)
+
+// parse parses src and fails the test on errors.
+func parse(t *testing.T, filename, src string) *ast.File {
+	t.Helper()
+	file, diags := antlr.ParseFile(filename, src)
+	if len(diags) > 0 {
+		t.Fatalf("%s: unexpected errors:\n%v", filename, diags)
+	}
+	return file
+}
+
+// printNode prints node in the given mode and fails the test on errors.
+func printNode(t *testing.T, node ast.Node, mode Mode) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (&Config{Mode: mode}).Fprint(&buf, node); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	return buf.String()
+}
+
+// Sources in printer format, which print back unchanged.
+var roundTrips = []string{
+	`// Package main is a test.
+package main
+
+import (
+	"fmt"
+	m "math"
+)
+
+const (
+	A    = 1 // first
+	Long = 2
+)
+const B = 3
+
+var x, y int
+
+/* Point is a point. */
+type Point struct {
+	X, Y  int32 ` + "`json:\"x\"`" + `
+	Label *[]byte
+	Point
+}
+
+type List[T any] struct {
+	items *[]T
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+type Shape interface {
+	Area() float64
+	fmt.Stringer
+}
+
+func (p *Point) Scale(f int32) (x, y int32) {
+	return p.X * f, p.Y * f
+}
+
+func Map[T, U any](s *[]T, f func(T) U) *[]U {
+	out := &[]U{}
+	for _, v := range *s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func main() {
+	// Statements
+	a, b := 1, m.Sqrt(2)
+	a += -(a + 1) * 2
+	if v := a; v > 0 && !false {
+		a--
+	} else if v < 0 {
+		a++
+	} else {
+		go func() { fmt.Println(b) }()
+	}
+
+	switch a {
+	case 1, 2:
+		fallthrough
+	default:
+	}
+	switch t := interface{}(a).(type) {
+	case int:
+		_ = t
+	}
+	ch := make(chan int, 1)
+	select {
+	case v := <-ch:
+		_ = v
+	case ch <- 1:
+	default:
+	}
+
+loop:
+	for i := 0; i < 10; i++ {
+		continue loop
+	}
+	for {
+		break
+	}
+	u32 := (*[]uint32, LittleEndian)(x)
+	fmt.Printf("%d %d\n",
+		a, u32)
+	m := &map[string]int{
+		"one":   1,
+		"three": 3,
+	}
+	defer delete(*m, "one")
+}
+`,
+}
+
+func TestRoundTrip(t *testing.T) {
+	for i, src := range roundTrips {
+		file := parse(t, "test.mx", src)
+		if got := printNode(t, file, Moxie); got != src {
+			t.Errorf("source %d: got\n%s\nwant\n%s", i, got, src)
+		}
+	}
+}
+
+// TestExamples checks that the examples print as sources which parse to
+// the same output again.
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*/*.x")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, diags := antlr.ParseFile(name, string(src))
+		if len(diags) > 0 {
+			t.Logf("%s: skipped: %v", name, diags[0])
+			continue
+		}
+		out := printNode(t, file, Moxie)
+		if again := printNode(t, parse(t, name, out), Moxie); again != out {
+			t.Errorf("%s: output changes when printed again:\n%s", name, again)
+		}
+	}
+}
+
+func TestGoMode(t *testing.T) {
+	file := parse(t, "test.mx", `package main
+
+type T struct {
+	A int // a
+	LongName string
+}
+
+func f() { x := &[]int{1,2}; _ = x }
+`)
+	want := `package main
+
+type T struct {
+	A        int // a
+	LongName string
+}
+
+func f() { x := &[]int{1, 2}; _ = x }
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	file = parse(t, "test.mx", "package main\n\nfunc f() {\n\tu := (*[]uint32, BigEndian)(b)\n}\n")
+	var buf bytes.Buffer
+	err := (&Config{Mode: Go}).Fprint(&buf, file)
+	if err == nil || !strings.Contains(err.Error(), "test.mx:4:7: slice cast") {
+		t.Errorf("expected slice cast error, got %v", err)
+	}
+}
+
+func TestBuildConstraint(t *testing.T) {
+	src := "//moxie:build linux && amd64\n\n// Package main is a test.\npackage main\n"
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+	want := "//go:build linux && amd64\n\n// Package main is a test.\npackage main\n"
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	file = parse(t, "test.mx", "//moxie:build linux &&\n\npackage main\n")
+	err := (&Config{Mode: Go}).Fprint(&bytes.Buffer{}, file)
+	if err == nil || !strings.Contains(err.Error(), "test.mx:1:1: invalid build constraint") {
+		t.Errorf("expected invalid build constraint error, got %v", err)
+	}
+
+	// Only comments before the package clause are constraints.
+	src = "//moxie:build linux\n\npackage main\n\n//moxie:build darwin\nconst a = 1\n"
+	want = "//go:build linux\n\npackage main\n\n//moxie:build darwin\nconst a = 1\n"
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, src := range []string{
+		"//moxie:build linux\n//moxie:build amd64\n\npackage main\n",
+		"//go:build linux\n//moxie:build amd64\n\npackage main\n",
+	} {
+		file = parse(t, "test.mx", src)
+		err = (&Config{Mode: Go}).Fprint(&bytes.Buffer{}, file)
+		if err == nil || !strings.Contains(err.Error(), "test.mx:2:1: multiple build constraints") {
+			t.Errorf("%q: expected multiple build constraints error, got %v", src, err)
+		}
+	}
+}
+
+func TestChanLit(t *testing.T) {
+	lit := &ast.ChanLit{Type: &ast.Ident{Name: "int"}, Cap: &ast.BasicLit{Kind: ast.IntLit, Value: "10"}}
+	if got, want := printNode(t, lit, Moxie), "&chan int{cap: 10}"; got != want {
+		t.Errorf("Moxie: got %q, want %q", got, want)
+	}
+	if got, want := printNode(t, lit, Go), "func() *chan int { c := make(chan int, 10); return &c }()"; got != want {
+		t.Errorf("Go: got %q, want %q", got, want)
+	}
+
+	// Parsed literals keep their form.
+	src := "package main\n\nfunc f(n int32) {\n\tg(&chan<- int32{n * 2}, &<-chan string{cap: 1}, &chan bool{})\n}\n"
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+	want := `package main
+
+func f(n int32) {
+	g(func() *chan<- int32 { c := make(chan<- int32, n*2); return &c }(), func() *<-chan string { c := make(<-chan string, 1); return &c }(), func() *chan bool { c := make(chan bool); return &c }())
+}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSetLit(t *testing.T) {
+	src := `package main
+
+var seen = &map[string]*set[int32]{
+	"a": &set[int32]{1, 2},
+	"b": &set[int32]{},
+}
+
+var names = &set[string]{
+	"x",
+	"y",
+}
+`
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+
+	want := `package main
+
+var seen = &map[string]*map[int32]struct{}{
+	"a": &map[int32]struct{}{1: {}, 2: {}},
+	"b": &map[int32]struct{}{},
+}
+
+var names = &map[string]struct{}{
+	"x": {},
+	"y": {},
+}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	// A declaration named set hides the set type.
+	src = `package main
+
+type set[T comparable] map[T]bool
+
+var s = &set[int32]{}
+
+func f(n int32) {
+	var t set[int32]
+	{
+		set := &[]int32{1}
+		n = (*set)[0]
+	}
+	println(t, n)
+}
+`
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != src {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, src)
+	}
+}
+
+func TestConstType(t *testing.T) {
+	src := `package main
+
+type View struct {
+	data const *[]byte
+}
+
+func f(p const *[]byte, q *const []byte) {}
+`
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+
+	want := `package main
+
+type View struct {
+	data *[]byte
+}
+
+func f(p *[]byte, q *[]byte) {}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConstDecl(t *testing.T) {
+	src := `package main
+
+const Limit = int32(len("abc")) * -2
+
+const Config = &map[string]int32{"a": 1}
+
+const (
+	Default = Config
+	Name    = "x"
+)
+
+func f() {
+	const n = Limit + 1
+	const b = &[]byte{n}
+	println(b)
+}
+`
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+
+	want := `package main
+
+const Limit = int32(len("abc")) * -2
+
+var Config = &map[string]int32{"a": 1}
+
+var (
+	Default = Config
+	Name    = "x"
+)
+
+func f() {
+	const n = Limit + 1
+	var b = &[]byte{n}
+	println(b)
+}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	file = parse(t, "test.mx", "package main\n\nconst (\n\tA = &[]byte{}\n\tB\n)\n")
+	var buf bytes.Buffer
+	err := (&Config{Mode: Go}).Fprint(&buf, file)
+	if err == nil || !strings.Contains(err.Error(), "const B repeats a value that is not a Go constant") {
+		t.Errorf("Go: got error %v", err)
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	src := "package main\n\nfunc f(name string, n int) string {\n\treturn \"${name}: ${n * 2}% $${x}\"\n}\n"
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+
+	want := `package main
+
+import "fmt"
+
+func f(name string, n int) string {
+	return fmt.Sprintf("%v: %v%% ${x}", name, n*2)
+}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	// An existing import of fmt is used under its name.
+	file = parse(t, "test.mx", "package main\n\nimport f \"fmt\"\n\nvar s = \"a${b}\"\nvar t = \"$${c}\"\n")
+	want = "package main\n\nimport f \"fmt\"\n\nvar s = f.Sprintf(\"a%v\", b)\nvar t = \"${c}\"\n"
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+	// A hidden fmt is imported again under a fresh name, and an added
+	// import joins the existing ones.
+	src = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func f() {
+	fmt := 3
+	fmt2 := "${fmt}"
+	println(fmt2, os.Args)
+}
+`
+	want = `package main
+
+import (
+	"fmt"
+	fmt3 "fmt"
+	"os"
+)
+
+func f() {
+	fmt := 3
+	fmt2 := fmt3.Sprintf("%v", fmt)
+	println(fmt2, os.Args)
+}
+`
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+	src = "package main\n\nimport \"os\"\n\nvar s = \"${os.Args}\"\n"
+	want = "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar s = fmt.Sprintf(\"%v\", os.Args)\n"
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	// Names declared as *[]byte are formatted as text, and escapes of %
+	// are escaped in the format.
+	src = `package main
+
+func f(name *[]byte, view const *[]byte) {
+	s := &[]byte{'a'}
+	println("${name} ${view} ${s}")
+	if name := 1; name > 0 {
+		println("${name}")
+	}
+	{
+		name := "100\x25 ${name}\t"
+		println(name)
+	}
+}
+`
+	want = `package main
+
+import "fmt"
+
+func f(name *[]byte, view *[]byte) {
+	s := &[]byte{'a'}
+	println(fmt.Sprintf("%s %s %s", *name, *view, *s))
+	if name := 1; name > 0 {
+		println(fmt.Sprintf("%v", name))
+	}
+	{
+		name := fmt.Sprintf("100%% %s\t", *name)
+		println(name)
+	}
+}
+`
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSort(t *testing.T) {
+	src := `package main
+
+func f(s *[]int32) {
+	slices := 1
+	sort(s)
+	println(slices)
+}
+`
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+
+	want := `package main
+
+import slices2 "slices"
+
+func f(s *[]int32) {
+	slices := 1
+	slices2.Sort(*s)
+	println(slices)
+}
+`
+	if got := printNode(t, file, Go); got != want {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, want)
+	}
+
+	x := &ast.CallExpr{Fun: &ast.Ident{Name: "sort"}, Args: []ast.Expr{&ast.Ident{Name: "s"}}}
+	if got, want := printNode(t, x, Go), "slices.Sort(*s)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Invalid calls of the builtin, and comparators of unknown element
+	// type, are errors.
+	for _, tt := range []struct{ src, err string }{
+		{"func f(s *[]int32) { sort(s...) }", "cannot use ... with builtin sort"},
+		{"func f(s *[]int32) { sort(s, s, s) }", "builtin sort takes 1 or 2 arguments, not 3"},
+		{"func f(s *[]int32) { assert() }", "builtin assert takes at least 1 argument"},
+		{"func f(less func(a, b int32) bool) { sort(g(), less) }", ""},
+		{"func f(s *[]int32, less Less) { sort(s, less) }", ""},
+		{"func f(less Less) { sort(g(), less) }", "the element type of the slice is unknown"},
+	} {
+		var buf bytes.Buffer
+		err := (&Config{Mode: Go}).Fprint(&buf, parse(t, "test.mx", "package main\n\n"+tt.src+"\n"))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got error %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestAssert(t *testing.T) {
+	src := `package main
+
+func f(ok bool) {
+	assert(ok)
+	defer assert(ok)
+}
+`
+	file := parse(t, "test.mx", src)
+	if got := printNode(t, file, Moxie); got != src {
+		t.Errorf("Moxie: got\n%s\nwant\n%s", got, src)
+	}
+	var buf bytes.Buffer
+	err := (&Config{Mode: Go}).Fprint(&buf, file)
+	if err == nil || !strings.Contains(err.Error(), "test.mx:5:8: assert outside an expression statement has no Go equivalent") {
+		t.Errorf("Go: got error %v", err)
+	}
+
+	// A declaration named assert is called as usual.
+	src = "package main\n\nfunc assert(ok bool) {}\n\nfunc f() {\n\tassert(true)\n}\n"
+	if got := printNode(t, parse(t, "test.mx", src), Go); got != src {
+		t.Errorf("Go: got\n%s\nwant\n%s", got, src)
+	}
+
+	// A release build omits asserts with their arguments, and the imports
+	// only they use.
+	src = `package main
+
+func f(n int32) {
+	assert(n > 0, "${n}")
+	g := func() { assert(check(n)); println(n) }
+	g()
+}
+`
+	want := `package main
+
+func f(n int32) {
+	g := func() { println(n) }
+	g()
+}
+`
+	buf.Reset()
+	if err := (&Config{Mode: Go, Release: true}).Fprint(&buf, parse(t, "test.mx", src)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Release: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestDocFields checks that nodes built without positions get their doc
+// and line comments printed.
+func TestDocFields(t *testing.T) {
+	comment := func(text string) *ast.CommentGroup {
+		return &ast.CommentGroup{List: []*ast.Comment{{Text: text}}}
+	}
+	decl := &ast.VarDecl{
+		Doc: comment("// Count is a counter."),
+		Specs: []*ast.VarSpec{{
+			Names:   []*ast.Ident{{Name: "Count"}},
+			Type:    &ast.Ident{Name: "int"},
+			Comment: comment("// starts at zero"),
+		}},
+	}
+	want := "// Count is a counter.\nvar Count int // starts at zero"
+	if got := printNode(t, decl, Moxie); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParenthesize(t *testing.T) {
+	// (a + b) * c, built without a ParenExpr
+	x := &ast.BinaryExpr{
+		X:  &ast.BinaryExpr{X: &ast.Ident{Name: "a"}, Op: ast.ADD, Y: &ast.Ident{Name: "b"}},
+		Op: ast.MUL,
+		Y:  &ast.Ident{Name: "c"},
+	}
+	if got, want := printNode(t, x, Moxie), "(a + b) * c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
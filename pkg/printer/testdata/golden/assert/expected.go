@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func parse(buf *[]byte, n int32, name *[]byte) {
+	if !(len(*buf) >= 4) {
+		panic("input.mx:4: assertion failed")
+	}
+	if !(n > 0 && n < 10) {
+		panic("input.mx:5: assertion failed: " + fmt.Sprint("n out of range: ", n, ", in ", string(*name)))
+	}
+	if n == 3 {
+		panic("input.mx:6: assertion failed")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build linux && (amd64 || arm64)
+
+// Package sys is only built on 64-bit Linux.
+package sys
+
+const PageSize = 4096
@@ -0,0 +1,8 @@
+package main
+
+func pipeline() {
+	done := func() *chan struct{} { c := make(chan struct{}); return &c }()
+	jobs := func() *chan int32 { c := make(chan int32, 16); return &c }()
+	results := func() *<-chan int32 { c := make(<-chan int32, 4); return &c }()
+	_, _, _ = done, jobs, results
+}
@@ -0,0 +1,19 @@
+package main
+
+const Limit = 10
+
+var Config = &map[string]int32{"a": 1}
+
+type View struct {
+	data *[]byte
+}
+
+func sum(data *[]byte) int64 {
+	var total int64
+	for _, b := range *data {
+		total += int64(b)
+	}
+	(*data)[0] = 0
+	(*Config)["a"] = 2
+	return total
+}
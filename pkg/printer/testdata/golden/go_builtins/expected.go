@@ -0,0 +1,8 @@
+package main
+
+func collect(xs *[]int32, ys *[]int32) {
+	buf := make([]int32, 0, 8)
+	m := make(map[string]int32)
+	*xs = append(*xs, *ys...)
+	_, _ = buf, m
+}
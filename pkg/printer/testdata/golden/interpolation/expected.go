@@ -0,0 +1,8 @@
+package main
+
+import "fmt"
+
+func greet(name *[]byte, n int32) {
+	msg := fmt.Sprintf("hello %s, you are %v%% done; ${literal}", *name, n+1)
+	_ = msg
+}
@@ -0,0 +1,7 @@
+package main
+
+var seen = &map[string]struct{}{"a": {}, "b": {}}
+
+func index(groups *map[string]*map[int32]struct{}) *map[int32]struct{} {
+	return (*groups)["x"]
+}
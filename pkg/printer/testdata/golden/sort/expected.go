@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"slices"
+)
+
+type Person struct {
+	Name string
+	Age  int32
+}
+
+func byName(a, b Person) bool {
+	return a.Name < b.Name
+}
+
+func order(ns *[]int32, people *[]Person) {
+	slices.Sort(*ns)
+	slices.SortFunc(*people, func(less func(a, b Person) bool) func(a, b Person) int {
+		return func(a, b Person) int {
+			if less(a, b) {
+				return -1
+			}
+			if less(b, a) {
+				return 1
+			}
+			return 0
+		}
+	}(func(a, b Person) bool { return a.Age < b.Age }))
+	slices.SortFunc(*people, func(less func(a, b Person) bool) func(a, b Person) int {
+		return func(a, b Person) int {
+			if less(a, b) {
+				return -1
+			}
+			if less(b, a) {
+				return 1
+			}
+			return 0
+		}
+	}(byName))
+	if len(os.Args) > 1 {
+		sort := func(p *[]Person) {}
+		sort(people)
+	}
+}